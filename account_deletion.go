@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+// deletedUserID is the placeholder author of chirps that outlive their
+// author's account because other users replied to them. It is seeded by
+// migration 023 and must differ from uuid.Nil, which optionalUserID returns
+// for signed-out viewers.
+var deletedUserID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// accountPurgeBatchSize caps how many accounts one purge run removes.
+const accountPurgeBatchSize = 100
+
+func (cfg *apiConfig) handlerDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	type deleteAccountRequest struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		PurgeAt time.Time `json:"purge_at"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	var req deleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+	if req.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing required fields", nil)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+	if err := auth.CheckPasswordHash(req.Password, user.HashedPassword); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		user, err = q.RequestUserDeletion(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		return q.RevokeUserRefreshTokens(r.Context(), userID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete account", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		PurgeAt: user.DeletionRequestedAt.Time.Add(cfg.accountDeletionGracePeriod),
+	})
+}
+
+// purgeDeletedAccounts permanently removes accounts whose deletion grace
+// period has passed.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) {
+	userIDs, err := cfg.db.GetUsersDueForPurge(ctx, database.GetUsersDueForPurgeParams{
+		GracePeriodSeconds: cfg.accountDeletionGracePeriod.Seconds(),
+		MaxResults:         accountPurgeBatchSize,
+	})
+	if err != nil {
+		slog.Error("Error listing accounts to purge", "error", err)
+		return
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		if err := cfg.withTx(ctx, func(q *database.Queries) error {
+			return purgeAccount(ctx, q, userID)
+		}); err != nil {
+			slog.Error("Error purging account", "user_id", userID, "error", err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		slog.Info("Purged deleted accounts", "count", purged)
+	}
+}
+
+// purgeAccount deletes a user. Chirps that other users replied to, and the
+// user's own replies to other users' chirps, are kept so their threads stay
+// intact, but lose their body, revisions and poll and are reassigned to the
+// placeholder author. Everything else the user owns
+// goes with the users row through ON DELETE CASCADE.
+func purgeAccount(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	kept, err := q.AnonymizeRepliedChirps(ctx, database.AnonymizeRepliedChirpsParams{
+		DeletedUserID: deletedUserID,
+		UserID:        userID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, chirpID := range kept {
+		if err := q.DeleteChirpRevisions(ctx, chirpID); err != nil {
+			return err
+		}
+		if err := q.DeletePollForChirp(ctx, chirpID); err != nil {
+			return err
+		}
+	}
+	return q.DeleteUser(ctx, userID)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+// accountRestriction describes why a user may not use the API.
+type accountRestriction struct {
+	Banned          bool
+	SuspendedUntil  time.Time
+	PendingDeletion bool
+}
+
+func (a *accountRestriction) Error() string {
+	if a.Banned {
+		return "account is banned"
+	}
+	if a.PendingDeletion {
+		return "account is scheduled for deletion"
+	}
+	return "account is suspended until " + a.SuspendedUntil.Format(time.RFC3339)
+}
+
+// restrictionFor returns the user's ban, current suspension or pending
+// deletion, or nil if they are in good standing.
+func restrictionFor(user database.User) *accountRestriction {
+	if user.BannedAt.Valid {
+		return &accountRestriction{Banned: true}
+	}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now()) {
+		return &accountRestriction{SuspendedUntil: user.SuspendedUntil.Time}
+	}
+	if user.DeletionRequestedAt.Valid {
+		return &accountRestriction{PendingDeletion: true}
+	}
+	return nil
+}
+
+// respondIfRestricted responds with 403 Forbidden and returns true if the
+// user is banned, suspended or scheduled for deletion.
+func respondIfRestricted(w http.ResponseWriter, user database.User) bool {
+	type restrictedResponse struct {
+		Error          string     `json:"error"`
+		SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	}
+
+	restriction := restrictionFor(user)
+	if restriction == nil {
+		return false
+	}
+
+	resp := restrictedResponse{Error: "Account is banned"}
+	switch {
+	case restriction.Banned:
+	case restriction.PendingDeletion:
+		resp.Error = "Account is scheduled for deletion; log in to restore it"
+	default:
+		resp.Error = "Account is suspended"
+		resp.SuspendedUntil = &restriction.SuspendedUntil
+	}
+	respondWithJSON(w, http.StatusForbidden, resp)
+	return true
+}
+
+// authContextKey is the request context key for the authenticatedRequest
+// set by middlewareAccountStatus.
+type authContextKey struct{}
+
+// authenticatedRequest is the access token a request was authenticated
+// with and the user it belongs to, as loaded by middlewareAccountStatus.
+type authenticatedRequest struct {
+	token     string
+	expiresAt time.Time
+	user      database.User
+}
+
+// publicRoutes are the routes that don't take an access token, so
+// middlewareAccountStatus doesn't look one up for them.
+var publicRoutes = map[string]bool{
+	"/app/":                                true,
+	"GET /api/healthz":                     true,
+	"GET /metrics":                         true,
+	"GET /admin/metrics":                   true,
+	"POST /admin/reset":                    true,
+	"POST /api/users":                      true,
+	"POST /api/login":                      true,
+	"POST /api/refresh":                    true,
+	"POST /api/revoke":                     true,
+	"POST /api/polka/webhooks":             true,
+	"GET /api/exports/{exportID}/download": true,
+}
+
+// middlewareAccountStatus rejects requests carrying a valid access token for
+// a banned, suspended or deleted user, so restrictions take effect without
+// waiting for the token to expire. The token is validated and the user
+// loaded once, and both are put in the request context for
+// validateAccessToken and requireRole. Requests without an access token, or
+// with one that doesn't validate, are left for the handler to deal with.
+func (cfg *apiConfig) middlewareAccountStatus(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); publicRoutes[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			token = r.URL.Query().Get("access_token")
+		}
+		if token == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		userID, expiresAt, err := auth.ValidateJWTExpiry(token, cfg.secret)
+		if err != nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		setRequestUserID(w, userID)
+
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Unable to load user", err)
+			return
+		}
+		if respondIfRestricted(w, user) {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authContextKey{}, authenticatedRequest{
+			token:     token,
+			expiresAt: expiresAt,
+			user:      user,
+		})
+		authed := r.WithContext(ctx)
+		mux.ServeHTTP(w, authed)
+		// The logging, metrics and tracing middleware read the route the
+		// ServeMux matched from r, not from the copy it was set on.
+		r.Pattern = authed.Pattern
+	})
+}
+
+// authenticatedFor returns what middlewareAccountStatus stored for r if it
+// authenticated r with token.
+func authenticatedFor(r *http.Request, token string) (authenticatedRequest, bool) {
+	authed, ok := r.Context().Value(authContextKey{}).(authenticatedRequest)
+	return authed, ok && authed.token == token
+}
+
+// validateAccessToken returns the ID of the user token was issued to. A
+// token middlewareAccountStatus has already validated isn't parsed again.
+func (cfg *apiConfig) validateAccessToken(r *http.Request, token string) (uuid.UUID, error) {
+	userID, _, err := cfg.validateAccessTokenExpiry(r, token)
+	return userID, err
+}
+
+// validateAccessTokenExpiry is validateAccessToken, also returning when
+// token expires.
+func (cfg *apiConfig) validateAccessTokenExpiry(r *http.Request, token string) (uuid.UUID, time.Time, error) {
+	if authed, ok := authenticatedFor(r, token); ok {
+		return authed.user.ID, authed.expiresAt, nil
+	}
+	return auth.ValidateJWTExpiry(token, cfg.secret)
+}
+
+// isAuthorRestricted reports whether the author of a chirp is banned,
+// suspended or scheduled for deletion, which hides their chirps from
+// everyone else.
+func (cfg *apiConfig) isAuthorRestricted(ctx context.Context, authorID uuid.UUID) (bool, error) {
+	author, err := cfg.db.GetUserByID(ctx, authorID)
+	if err != nil {
+		return false, err
+	}
+	return restrictionFor(author) != nil, nil
+}
+
+// setAccountRestriction applies a suspend, unsuspend, ban or unban action
+// to a user. Banning also revokes their refresh tokens. Suspending and
+// banning return an account.restricted event, which closes the user's open
+// streams once it is published after q's transaction has committed.
+func setAccountRestriction(ctx context.Context, q *database.Queries, userID uuid.UUID, action string, suspendedUntil sql.NullTime) (*database.OutboxEvent, error) {
+	var err error
+	switch action {
+	case moderationActionSuspend, moderationActionUnsuspend:
+		err = q.SuspendUser(ctx, database.SuspendUserParams{
+			ID:             userID,
+			SuspendedUntil: suspendedUntil,
+		})
+	case moderationActionBan:
+		if err = q.BanUser(ctx, userID); err == nil {
+			err = q.RevokeUserRefreshTokens(ctx, userID)
+		}
+	case moderationActionUnban:
+		err = q.UnbanUser(ctx, userID)
+	}
+	if err != nil || (action != moderationActionSuspend && action != moderationActionBan) {
+		return nil, err
+	}
+
+	event, err := recordEvent(ctx, q, eventAccountRestricted, userID, false, accountRestrictedEvent{
+		UserID: userID,
+		Action: action,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRank orders roles by authority.
+var roleRank = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+// outranks reports whether a user with role may moderate one with target,
+// which takes a strictly higher role.
+func outranks(role, target string) bool {
+	return roleRank[role] > roleRank[target]
+}
+
+// requireRole authenticates the caller and checks that they have one of the
+// given roles. On failure it writes the error response and returns false.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return database.User{}, false
+	}
+
+	// middlewareAccountStatus has usually loaded the caller already.
+	authed, ok := authenticatedFor(r, token)
+	user := authed.user
+	if !ok {
+		userID, err := cfg.validateAccessToken(r, token)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+			return database.User{}, false
+		}
+		user, err = cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+			return database.User{}, false
+		}
+	}
+
+	if !slices.Contains(roles, user.Role) {
+		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		return database.User{}, false
+	}
+	return user, true
+}
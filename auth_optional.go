@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+)
+
+// optionalUserID returns the authenticated caller's ID, or uuid.Nil when the
+// request carries no Authorization header. A header that is present but
+// invalid is still an error.
+func (cfg *apiConfig) optionalUserID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return cfg.validateAccessToken(r, token)
+}
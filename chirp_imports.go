@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/archive"
+	"github.com/santokan/go-httpserver/internal/database"
+	"github.com/santokan/go-httpserver/internal/entitlements"
+)
+
+const (
+	chirpImportPending   = "pending"
+	chirpImportRunning   = "running"
+	chirpImportCompleted = "completed"
+	chirpImportFailed    = "failed"
+)
+
+const (
+	chirpImportBatchSize   = 2
+	maxChirpImportAttempts = 3
+	// chirpImportChunkSize is how many lines are committed together. A
+	// resumed import redoes at most one chunk's worth of work.
+	chirpImportChunkSize = 100
+	// chirpImportLease is how long a claimed import is hidden from other
+	// instances. It is extended after every chunk, so it only runs out if
+	// the process dies or the database is unavailable.
+	chirpImportLease = 5 * time.Minute
+)
+
+// processChirpImports works through pending and interrupted imports.
+func (cfg *apiConfig) processChirpImports(ctx context.Context) {
+	imports, err := cfg.db.ClaimChirpImports(ctx, database.ClaimChirpImportsParams{
+		LeaseSeconds: chirpImportLease.Seconds(),
+		MaxResults:   chirpImportBatchSize,
+	})
+	if err != nil {
+		slog.Error("Error claiming chirp imports", "error", err)
+		return
+	}
+
+	for _, imp := range imports {
+		cfg.runChirpImport(ctx, imp)
+	}
+}
+
+// runChirpImport imports the rest of an upload, starting after the last line
+// an earlier attempt committed. Problems with the upload itself fail the
+// import and delete the upload. Database errors leave it to be retried once
+// its lease runs out.
+func (cfg *apiConfig) runChirpImport(ctx context.Context, imp database.ChirpImport) {
+	fail := func(err error) {
+		slog.Warn("Chirp import failed", "import_id", imp.ID, "error", err)
+		if err := cfg.db.FailChirpImport(ctx, database.FailChirpImportParams{
+			ID:    imp.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			slog.Error("Error marking chirp import failed", "import_id", imp.ID, "error", err)
+		}
+	}
+
+	if imp.Attempts > maxChirpImportAttempts {
+		fail(errors.New("too many attempts"))
+		return
+	}
+
+	data, err := cfg.db.GetChirpImportUpload(ctx, imp.ID)
+	if err != nil {
+		slog.Error("Error loading chirp import", "import_id", imp.ID, "error", err)
+		return
+	}
+	reader, err := archive.OpenChirps(data)
+	if err != nil {
+		fail(err)
+		return
+	}
+	limits, err := cfg.limitsForUser(ctx, imp.UserID)
+	if err != nil {
+		slog.Error("Error loading entitlements for chirp import", "import_id", imp.ID, "error", err)
+		return
+	}
+
+	for reader.Line() < int(imp.Position) {
+		_, err := reader.Next()
+		var lineErr *archive.LineError
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.As(err, &lineErr) {
+			fail(err)
+			return
+		}
+	}
+
+	for {
+		done, retryAfter, err := cfg.importChirpChunk(ctx, imp, reader, limits)
+		if errors.Is(err, errUnreadableUpload) {
+			fail(err)
+			return
+		}
+		if err != nil {
+			slog.Error("Error importing chirps", "import_id", imp.ID, "error", err)
+			return
+		}
+		if retryAfter > 0 {
+			// The hourly import quota has been used up. The import
+			// carries on once it resets.
+			err := cfg.db.PauseChirpImport(ctx, database.PauseChirpImportParams{
+				ResumeAfterSeconds: retryAfter.Seconds(),
+				ID:                 imp.ID,
+			})
+			if err != nil {
+				slog.Error("Error pausing chirp import", "import_id", imp.ID, "error", err)
+			}
+			return
+		}
+		if done {
+			break
+		}
+	}
+
+	err = cfg.withTx(ctx, func(q *database.Queries) error {
+		if err := q.CompleteChirpImport(ctx, imp.ID); err != nil {
+			return err
+		}
+		return q.DeleteChirpImportUpload(ctx, imp.ID)
+	})
+	if err != nil {
+		slog.Error("Error completing chirp import", "import_id", imp.ID, "error", err)
+	}
+}
+
+// errUnreadableUpload marks an import that can't be retried because its
+// upload can't be read past some point.
+var errUnreadableUpload = errors.New("unreadable upload")
+
+// allowImportedChirp counts an imported chirp against the hourly import
+// quota. Imports restore history rather than publish new chirps, so they
+// have their own quota and don't use up the slots of the chirp rate limit.
+func (cfg *apiConfig) allowImportedChirp(userID uuid.UUID) (bool, time.Duration) {
+	return cfg.chirpLimiter.Allow(importLimiterKey(userID), cfg.importChirpsPerHour)
+}
+
+// releaseImportedChirps gives back count slots taken by allowImportedChirp.
+func (cfg *apiConfig) releaseImportedChirps(userID uuid.UUID, count int32) {
+	for range count {
+		cfg.chirpLimiter.Release(importLimiterKey(userID))
+	}
+}
+
+func importLimiterKey(userID uuid.UUID) string {
+	return "import:" + userID.String()
+}
+
+// importChirpChunk imports up to chirpImportChunkSize lines in one
+// transaction, recording the position reached so the import can resume
+// from there. It reports whether the end of the upload was reached, or how
+// long until the user's import quota resets if it was reached first.
+func (cfg *apiConfig) importChirpChunk(ctx context.Context, imp database.ChirpImport, reader *archive.ChirpReader, limits entitlements.Limits) (bool, time.Duration, error) {
+	var done bool
+	var retryAfter time.Duration
+	var readErr error
+	var imported int32
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		var failed int32
+		recordError := func(line int, itemErr error) error {
+			failed++
+			return q.CreateChirpImportError(ctx, database.CreateChirpImportErrorParams{
+				ImportID: imp.ID,
+				Line:     int32(line),
+				Error:    itemErr.Error(),
+			})
+		}
+
+		for range chirpImportChunkSize {
+			record, err := reader.Next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			var lineErr *archive.LineError
+			if errors.As(err, &lineErr) {
+				if err := recordError(lineErr.Line, lineErr.Err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				readErr = err
+				return err
+			}
+
+			params, err := cfg.validateImportedChirp(imp.UserID, record, limits.MaxChirpLength)
+			if err != nil {
+				if err := recordError(reader.Line(), err); err != nil {
+					return err
+				}
+				continue
+			}
+			if params.ImportedFromID.Valid {
+				// Chirps already in the account, from this archive or from
+				// the account it was exported from, are skipped.
+				exists, err := q.HasImportedChirp(ctx, database.HasImportedChirpParams{
+					UserID:     imp.UserID,
+					ArchivedID: params.ImportedFromID.UUID,
+				})
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+			}
+			var ok bool
+			if ok, retryAfter = cfg.allowImportedChirp(imp.UserID); !ok {
+				break
+			}
+			dbChirp, err := q.ImportChirp(ctx, params)
+			if err != nil {
+				return err
+			}
+			if err := cfg.flagChirpForReview(ctx, q, chirpFromDB(dbChirp)); err != nil {
+				return err
+			}
+			imported++
+		}
+
+		position := int32(reader.Line())
+		if retryAfter > 0 {
+			// Stop before the line that hit the quota, so it is read
+			// again when the import resumes.
+			position--
+		}
+		return q.AdvanceChirpImport(ctx, database.AdvanceChirpImportParams{
+			Position:     position,
+			Imported:     imported,
+			Failed:       failed,
+			LeaseSeconds: chirpImportLease.Seconds(),
+			ID:           imp.ID,
+		})
+	})
+	if err == nil {
+		cfg.metrics.chirpsCreated.WithLabelValues(chirpSourceImport).Add(float64(imported))
+	} else {
+		cfg.releaseImportedChirps(imp.UserID, imported)
+	}
+	if readErr != nil {
+		return false, 0, fmt.Errorf("%w: %v", errUnreadableUpload, readErr)
+	}
+	return done, retryAfter, err
+}
+
+// validateImportedChirp checks an archived chirp the way a new chirp is
+// checked, keeping its original creation time. Only published chirps are
+// imported; revisions, polls and reply links are dropped.
+func (cfg *apiConfig) validateImportedChirp(userID uuid.UUID, record archive.Chirp, maxChirpLength int) (database.ImportChirpParams, error) {
+	if record.Status != "" && record.Status != chirpStatusPublished {
+		return database.ImportChirpParams{}, errors.New("only published chirps can be imported")
+	}
+	if record.DeletedAt != nil {
+		return database.ImportChirpParams{}, errors.New("deleted chirps can't be imported")
+	}
+	if record.CreatedAt.IsZero() {
+		return database.ImportChirpParams{}, errors.New("created_at is required")
+	}
+	if record.CreatedAt.After(time.Now()) {
+		return database.ImportChirpParams{}, errors.New("created_at is in the future")
+	}
+
+	body, err := cfg.validateChirp(record.Body, maxChirpLength)
+	if err != nil {
+		return database.ImportChirpParams{}, err
+	}
+	visibility, err := validateVisibility(record.Visibility)
+	if err != nil {
+		return database.ImportChirpParams{}, err
+	}
+
+	return database.ImportChirpParams{
+		CreatedAt:  record.CreatedAt.UTC(),
+		Body:       body,
+		UserID:     userID,
+		Visibility: visibility,
+		ImportedFromID: uuid.NullUUID{
+			UUID:  record.ID,
+			Valid: record.ID != uuid.Nil,
+		},
+	}, nil
+}
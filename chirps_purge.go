@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// purgeDeletedChirps permanently removes chirps that have been soft-deleted
+// for longer than the configured retention period.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	purged, err := cfg.db.PurgeDeletedChirps(ctx, cfg.chirpRetention.Seconds())
+	if err != nil {
+		slog.Error("Error purging deleted chirps", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.Info("Purged deleted chirps", "count", purged)
+	}
+}
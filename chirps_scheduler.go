@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const scheduledChirpBatchSize = 100
+
+// publishScheduledChirps publishes every scheduled chirp whose publish_at has
+// passed. Rows are claimed with FOR UPDATE SKIP LOCKED, so several server
+// instances can run the scheduler at once without publishing a chirp twice.
+// A chirp whose author has reached their hourly chirp limit is postponed
+// until the limit resets. Chirps by banned, suspended or deleted authors
+// aren't claimed until the restriction is lifted.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context) {
+	for {
+		var claimed int
+		var published []database.Chirp
+		var events []database.OutboxEvent
+		// The authors of the chirps counted against the rate limit, so
+		// their slots can be given back if the batch rolls back.
+		var counted []uuid.UUID
+		err := cfg.withTx(ctx, func(q *database.Queries) error {
+			due, err := q.ClaimDueChirps(ctx, scheduledChirpBatchSize)
+			if err != nil {
+				return err
+			}
+			claimed = len(due)
+			for _, dbChirp := range due {
+				limits, err := cfg.limitsForUser(ctx, dbChirp.UserID)
+				if err != nil {
+					return err
+				}
+				if ok, retryAfter := cfg.allowChirp(dbChirp.UserID, limits); !ok {
+					err := q.PostponeScheduledChirp(ctx, database.PostponeScheduledChirpParams{
+						ID:        dbChirp.ID,
+						PublishAt: sql.NullTime{Time: time.Now().Add(retryAfter), Valid: true},
+					})
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				counted = append(counted, dbChirp.UserID)
+
+				dbChirp, err = q.PublishScheduledChirp(ctx, dbChirp.ID)
+				if err != nil {
+					return err
+				}
+				published = append(published, dbChirp)
+				chirp := chirpFromDB(dbChirp)
+				event, err := recordChirpCreated(ctx, q, chirp)
+				if err != nil {
+					return err
+				}
+				events = append(events, event)
+				if err := cfg.flagChirpForReview(ctx, q, chirp); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			cfg.releaseChirps(counted...)
+			slog.Error("Error publishing scheduled chirps", "error", err)
+			return
+		}
+		cfg.publishEvents(ctx, events...)
+		cfg.metrics.chirpsCreated.WithLabelValues(chirpSourceScheduled).Add(float64(len(published)))
+		if len(published) > 0 {
+			slog.Info("Published scheduled chirps", "count", len(published))
+		}
+		if claimed < scheduledChirpBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/contentfilter"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const reportReasonContentFilter = "content_filter"
+
+// reloadContentFilter rebuilds the content filter from the rules file, if
+// one is configured, and the rules stored in the database, then swaps it in
+// for requests that start afterwards.
+func (cfg *apiConfig) reloadContentFilter(ctx context.Context) error {
+	var rules []contentfilter.Rule
+	if cfg.contentFilterFile != "" {
+		fileRules, err := contentfilter.LoadFile(cfg.contentFilterFile)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	dbRules, err := cfg.db.GetContentFilterRules(ctx)
+	if err != nil {
+		return fmt.Errorf("loading content filter rules: %w", err)
+	}
+	for _, rule := range dbRules {
+		rules = append(rules, contentfilter.Rule{
+			Term:   rule.Term,
+			Action: contentfilter.Action(rule.Action),
+		})
+	}
+
+	filter, err := contentfilter.New(rules)
+	if err != nil {
+		return err
+	}
+	cfg.contentFilter.Store(filter)
+	return nil
+}
+
+// refreshContentFilter reloads the content filter, keeping the current one
+// if the new rules can't be loaded.
+func (cfg *apiConfig) refreshContentFilter(ctx context.Context) {
+	if err := cfg.reloadContentFilter(ctx); err != nil {
+		slog.Error("Error reloading content filter", "error", err)
+	}
+}
+
+// flagChirpForReview files a report for moderators when the chirp's body
+// matches a rule whose action is flag.
+func (cfg *apiConfig) flagChirpForReview(ctx context.Context, q *database.Queries, chirp Chirp) error {
+	result := cfg.contentFilter.Load().Apply(chirp.Body)
+	if !result.Flagged() {
+		return nil
+	}
+
+	_, err := q.CreateReport(ctx, database.CreateReportParams{
+		UserID:  chirp.UserID,
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Reason:  reportReasonContentFilter,
+		Details: "Matched content filter terms: " + strings.Join(result.Terms(contentfilter.ActionFlag), ", "),
+	})
+	return err
+}
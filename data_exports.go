@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/archive"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	dataExportPending   = "pending"
+	dataExportRunning   = "running"
+	dataExportCompleted = "completed"
+	dataExportFailed    = "failed"
+)
+
+const (
+	dataExportBatchSize   = 2
+	maxDataExportAttempts = 3
+	// dataExportLease is how long a claimed export is hidden from other
+	// instances. If the process dies while building it, the export is
+	// picked up again once the lease runs out.
+	dataExportLease = 10 * time.Minute
+)
+
+// processDataExports builds pending export archives and removes expired
+// ones.
+func (cfg *apiConfig) processDataExports(ctx context.Context) {
+	exports, err := cfg.db.ClaimDataExports(ctx, database.ClaimDataExportsParams{
+		LeaseSeconds: dataExportLease.Seconds(),
+		MaxResults:   dataExportBatchSize,
+	})
+	if err != nil {
+		slog.Error("Error claiming data exports", "error", err)
+		return
+	}
+
+	for _, export := range exports {
+		cfg.runDataExport(ctx, export)
+	}
+
+	deleted, err := cfg.db.DeleteExpiredDataExports(ctx, cfg.exportRetention.Seconds())
+	if err != nil {
+		slog.Error("Error deleting expired data exports", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("Deleted expired data exports", "count", deleted)
+	}
+}
+
+func (cfg *apiConfig) runDataExport(ctx context.Context, export database.DataExport) {
+	fail := func(err error) {
+		slog.Error("Error building data export", "export_id", export.ID, "error", err)
+		if err := cfg.db.FailDataExport(ctx, database.FailDataExportParams{
+			ID:    export.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		}); err != nil {
+			slog.Error("Error marking data export failed", "export_id", export.ID, "error", err)
+		}
+	}
+
+	if export.Attempts > maxDataExportAttempts {
+		fail(errors.New("too many attempts"))
+		return
+	}
+
+	data, err := cfg.buildDataExport(ctx, export.UserID)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	err = cfg.withTx(ctx, func(q *database.Queries) error {
+		if err := q.CreateDataExportArchive(ctx, database.CreateDataExportArchiveParams{
+			ExportID: export.ID,
+			Archive:  data,
+		}); err != nil {
+			return err
+		}
+		return q.CompleteDataExport(ctx, database.CompleteDataExportParams{
+			RetentionSeconds: cfg.exportRetention.Seconds(),
+			ID:               export.ID,
+		})
+	})
+	if err != nil {
+		fail(err)
+	}
+}
+
+// buildDataExport writes the user's data as an archive.
+func (cfg *apiConfig) buildDataExport(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.exportChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := cfg.db.GetFollowsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	likes, err := cfg.db.GetLikesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := cfg.db.GetRefreshTokensByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := cfg.db.GetOutboxEventsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := cfg.db.GetModerationActionsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := archive.NewWriter(&buf)
+	if err := w.WriteJSON(archive.ProfileFile, archive.Profile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Role:        user.Role,
+		IsChirpyRed: user.IsChirpyRed,
+	}); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.ChirpsFile, chirps); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.FollowsFile, mapSlice(follows, func(f database.Follow) archive.Follow {
+		return archive.Follow{FollowerID: f.FollowerID, FolloweeID: f.FolloweeID, CreatedAt: f.CreatedAt}
+	})); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.LikesFile, mapSlice(likes, func(l database.Like) archive.Like {
+		return archive.Like{ChirpID: l.ChirpID, CreatedAt: l.CreatedAt}
+	})); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.SessionsFile, mapSlice(tokens, func(t database.RefreshToken) archive.Session {
+		return archive.Session{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt, RevokedAt: nullTimePtr(t.RevokedAt)}
+	})); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.EventsFile, mapSlice(events, func(e database.OutboxEvent) archive.Event {
+		return archive.Event{ID: e.ID, Type: e.EventType, CreatedAt: e.CreatedAt, Data: e.Payload}
+	})); err != nil {
+		return nil, err
+	}
+	if err := archive.WriteJSONLines(w, archive.ModerationFile, mapSlice(actions, func(a database.ModerationAction) archive.ModerationAction {
+		return archive.ModerationAction{
+			ID:             a.ID,
+			CreatedAt:      a.CreatedAt,
+			Action:         a.Action,
+			Reason:         a.Reason,
+			ChirpID:        nullUUIDPtr(a.ChirpID),
+			SuspendedUntil: nullTimePtr(a.SuspendedUntil),
+		}
+	})); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportChirps returns all of the user's chirps, including drafts and
+// deleted chirps that haven't been purged, with their revisions and polls.
+func (cfg *apiConfig) exportChirps(ctx context.Context, userID uuid.UUID) ([]archive.Chirp, error) {
+	dbChirps, err := cfg.db.GetChirpsForExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := cfg.db.GetChirpRevisionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	options, err := cfg.db.GetPollOptionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*archive.Chirp, len(dbChirps))
+	chirps := make([]archive.Chirp, len(dbChirps))
+	for i, c := range dbChirps {
+		chirps[i] = archive.Chirp{
+			ID:         c.ID,
+			CreatedAt:  c.CreatedAt,
+			UpdatedAt:  c.UpdatedAt,
+			Body:       c.Body,
+			Status:     c.Status,
+			Visibility: c.Visibility,
+			ReplyToID:  nullUUIDPtr(c.ReplyToID),
+			PublishAt:  nullTimePtr(c.PublishAt),
+			DeletedAt:  nullTimePtr(c.DeletedAt),
+		}
+		byID[c.ID] = &chirps[i]
+	}
+	for _, r := range revisions {
+		if c, ok := byID[r.ChirpID]; ok {
+			c.Revisions = append(c.Revisions, archive.Revision{CreatedAt: r.CreatedAt, Body: r.Body})
+		}
+	}
+	for _, o := range options {
+		c, ok := byID[o.ChirpID]
+		if !ok {
+			continue
+		}
+		if c.Poll == nil {
+			c.Poll = &archive.Poll{ExpiresAt: o.ExpiresAt}
+		}
+		c.Poll.Options = append(c.Poll.Options, o.Text)
+	}
+	return chirps, nil
+}
+
+func mapSlice[T, U any](items []T, f func(T) U) []U {
+	out := make([]U, len(items))
+	for i, item := range items {
+		out[i] = f(item)
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/entitlements"
+)
+
+// limitsForUser returns the entitlements of the plan userID is on.
+func (cfg *apiConfig) limitsForUser(ctx context.Context, userID uuid.UUID) (entitlements.Limits, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Limits{}, err
+	}
+	return cfg.entitlements.For(entitlements.PlanFor(user.IsChirpyRed)), nil
+}
+
+// allowChirp counts a chirp published by userID against their plan's hourly
+// limit. Every path that publishes a chirp goes through it, apart from
+// imports, which have their own quota. When the limit
+// has been reached, it reports how long until it resets. The count is kept
+// in memory, so each server instance applies the limit separately.
+func (cfg *apiConfig) allowChirp(userID uuid.UUID, limits entitlements.Limits) (bool, time.Duration) {
+	return cfg.chirpLimiter.Allow(userID.String(), limits.ChirpsPerHour)
+}
+
+// releaseChirps gives back the slots taken by allowChirp for chirps that
+// weren't published because their transaction rolled back. userIDs has one
+// entry per slot.
+func (cfg *apiConfig) releaseChirps(userIDs ...uuid.UUID) {
+	for _, userID := range userIDs {
+		cfg.chirpLimiter.Release(userID.String())
+	}
+}
+
+// errChirpRateLimited aborts a transaction that would publish a chirp over
+// the limit.
+var errChirpRateLimited = errors.New("chirp rate limit exceeded")
+
+func respondWithChirpRateLimit(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	respondWithError(w, http.StatusTooManyRequests, "Chirp rate limit exceeded", nil)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/contentfilter"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+type ContentFilterRule struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+}
+
+func contentFilterRuleFromDB(rule database.ContentFilterRule) ContentFilterRule {
+	return ContentFilterRule{
+		ID:        rule.ID,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+		Term:      rule.Term,
+		Action:    rule.Action,
+	}
+}
+
+func (cfg *apiConfig) handlerListContentFilterRules(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	dbRules, err := cfg.db.GetContentFilterRules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get content filter rules", err)
+		return
+	}
+
+	rules := make([]ContentFilterRule, 0, len(dbRules))
+	for _, rule := range dbRules {
+		rules = append(rules, contentFilterRuleFromDB(rule))
+	}
+	respondWithJSON(w, http.StatusOK, rules)
+}
+
+func (cfg *apiConfig) handlerCreateContentFilterRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	var req contentfilter.Rule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+	req.Term = strings.TrimSpace(req.Term)
+	if _, err := contentfilter.New([]contentfilter.Rule{req}); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rule: "+err.Error(), err)
+		return
+	}
+
+	rule, err := cfg.db.CreateContentFilterRule(r.Context(), database.CreateContentFilterRuleParams{
+		Term:   req.Term,
+		Action: string(req.Action),
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			respondWithError(w, http.StatusConflict, "A rule for this term already exists", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to create rule", err)
+		return
+	}
+	cfg.refreshContentFilter(r.Context())
+
+	respondWithJSON(w, http.StatusCreated, contentFilterRuleFromDB(rule))
+}
+
+func (cfg *apiConfig) handlerUpdateContentFilterRule(w http.ResponseWriter, r *http.Request) {
+	type updateRequest struct {
+		Action contentfilter.Action `json:"action"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rule ID", err)
+		return
+	}
+
+	var req updateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+	if !req.Action.Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid action", nil)
+		return
+	}
+
+	rule, err := cfg.db.UpdateContentFilterRule(r.Context(), database.UpdateContentFilterRuleParams{
+		ID:     ruleID,
+		Action: string(req.Action),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Rule not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to update rule", err)
+		return
+	}
+	cfg.refreshContentFilter(r.Context())
+
+	respondWithJSON(w, http.StatusOK, contentFilterRuleFromDB(rule))
+}
+
+func (cfg *apiConfig) handlerDeleteContentFilterRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid rule ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteContentFilterRule(r.Context(), ruleID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete rule", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Rule not found", nil)
+		return
+	}
+	cfg.refreshContentFilter(r.Context())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerReloadContentFilter reloads the rules file and database rules
+// without waiting for CONTENT_FILTER_RELOAD_INTERVAL.
+func (cfg *apiConfig) handlerReloadContentFilter(w http.ResponseWriter, r *http.Request) {
+	type reloadResponse struct {
+		Terms int `json:"terms"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	if err := cfg.reloadContentFilter(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reload content filter", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reloadResponse{
+		Terms: cfg.contentFilter.Load().Len(),
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+type WebhookEvent struct {
+	ID          string          `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	EventType   string          `json:"event_type"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   *string         `json:"last_error"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent, includePayload bool) WebhookEvent {
+	resp := WebhookEvent{
+		ID:         event.ID,
+		ReceivedAt: event.ReceivedAt,
+		UpdatedAt:  event.UpdatedAt,
+		EventType:  event.EventType,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+	}
+	if event.LastError.Valid {
+		resp.LastError = &event.LastError.String
+	}
+	if event.ProcessedAt.Valid {
+		resp.ProcessedAt = &event.ProcessedAt.Time
+	}
+	if includePayload {
+		resp.Payload = event.Payload
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 500
+	)
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", webhookStatusPending, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status filter", nil)
+		return
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	dbEvents, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:     status,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to list webhook events", err)
+		return
+	}
+
+	events := make([]WebhookEvent, 0, len(dbEvents))
+	for _, event := range dbEvents {
+		events = append(events, webhookEventFromDB(event, false))
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(r.Context(), r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event, true))
+}
+
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	eventID := r.PathValue("eventID")
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return
+	}
+
+	if event.Status != webhookStatusFailed && event.Status != webhookStatusPending {
+		respondWithError(w, http.StatusConflict, "Only failed events can be replayed", nil)
+		return
+	}
+
+	processErr := cfg.processWebhookEvent(r.Context(), eventID)
+
+	event, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load webhook event", err)
+		return
+	}
+
+	if processErr != nil && !errors.Is(processErr, errUserNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Replay failed", processErr)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event, true))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+// targetUser authenticates the request and parses the {userID} path value,
+// rejecting requests that target the caller. It writes an error response and
+// returns ok == false on failure.
+func (cfg *apiConfig) targetUser(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err = cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You cannot target yourself", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// handlerBlockUser blocks a user and removes any follows between the two
+// accounts, in either direction.
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.BlockUser(r.Context(), database.BlockUserParams{
+			BlockerID: userID,
+			BlockedID: targetID,
+		})
+		if err != nil {
+			return err
+		}
+		return q.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+			UserID:  userID,
+			OtherID: targetID,
+		})
+	})
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to block user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unblock user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to mute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unmute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
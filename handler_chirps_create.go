@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,15 +9,34 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/chirptext"
 	"github.com/santokan/go-httpserver/internal/database"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Body       string     `json:"body"`
+	Visibility string     `json:"visibility"`
+	ReplyToID  *uuid.UUID `json:"reply_to_id,omitempty"`
+	Poll       *Poll      `json:"poll,omitempty"`
+}
+
+func chirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:         dbChirp.ID,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+		Body:       dbChirp.Body,
+		UserID:     dbChirp.UserID,
+		Visibility: dbChirp.Visibility,
+	}
+	if dbChirp.ReplyToID.Valid {
+		chirp.ReplyToID = &dbChirp.ReplyToID.UUID
+	}
+	return chirp
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
@@ -27,7 +46,10 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Body string `json:"body"`
+		Body       string       `json:"body"`
+		Visibility string       `json:"visibility"`
+		Poll       *pollRequest `json:"poll"`
+		ReplyToID  *uuid.UUID   `json:"reply_to_id"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -36,66 +58,289 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(r, token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
 		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&req)
+	limits, err := cfg.limitsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load entitlements", err)
+		return
+	}
+
+	err = decodeTextRequest(r, &req)
+	if err != nil {
+		respondWithInvalidPayload(w, err)
+		return
+	}
+
+	cleanedBody, err := cfg.validateChirp(req.Body, limits.MaxChirpLength)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		respondWithInvalidChirp(w, err)
 		return
 	}
 
-	cleanedBody, err := validateChirp(req.Body)
+	visibility, err := validateVisibility(req.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid visibility", err)
+		return
+	}
+
+	var poll *validatedPoll
+	if req.Poll != nil {
+		validated, err := cfg.validatePoll(*req.Poll)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid poll", err)
+			return
+		}
+		poll = &validated
+	}
+
+	var replyTo *database.Chirp
+	if req.ReplyToID != nil {
+		parent, err := cfg.db.GetChirp(r.Context(), *req.ReplyToID)
+		if err != nil || parent.DeletedAt.Valid || parent.Status != chirpStatusPublished {
+			respondWithError(w, http.StatusBadRequest, "Chirp being replied to not found", err)
+			return
+		}
+		canView, err := cfg.canViewChirp(r.Context(), parent, userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Unable to get chirp", err)
+			return
+		}
+		if !canView {
+			respondWithError(w, http.StatusBadRequest, "Chirp being replied to not found", nil)
+			return
+		}
+		replyTo = &parent
+	}
+
+	mentioned, err := cfg.mentionedUserIDs(r.Context(), cleanedBody, userID, visibility)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp body", err)
+		respondWithError(w, http.StatusInternalServerError, "Unable to look up mentions", err)
+		return
+	}
+
+	// Only requests that would publish a chirp count against the limit.
+	if ok, retryAfter := cfg.allowChirp(userID, limits); !ok {
+		respondWithChirpRateLimit(w, retryAfter)
 		return
 	}
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID,
+	var chirp Chirp
+	var events []database.OutboxEvent
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var replyToID uuid.NullUUID
+		if replyTo != nil {
+			replyToID = uuid.NullUUID{UUID: replyTo.ID, Valid: true}
+		}
+		dbChirp, err := q.CreateChirp(r.Context(), database.CreateChirpParams{
+			Body:       cleanedBody,
+			UserID:     userID,
+			Visibility: visibility,
+			ReplyToID:  replyToID,
+		})
+		if err != nil {
+			return err
+		}
+		chirp = chirpFromDB(dbChirp)
+
+		if poll != nil {
+			chirp.Poll, err = createPoll(r.Context(), q, dbChirp.ID, *poll)
+			if err != nil {
+				return err
+			}
+		}
+		event, err := recordChirpCreated(r.Context(), q, chirp)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+
+		if err := cfg.flagChirpForReview(r.Context(), q, chirp); err != nil {
+			return err
+		}
+
+		if replyTo != nil && replyTo.UserID != userID {
+			event, err := notify(r.Context(), q, notificationParams{
+				UserID:  replyTo.UserID,
+				Type:    notificationTypeReply,
+				ChirpID: replyToID,
+				ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+		}
+
+		for _, mentionedID := range mentioned {
+			// The author of the chirp being replied to already gets a
+			// reply notification.
+			if replyTo != nil && mentionedID == replyTo.UserID {
+				continue
+			}
+			event, err := notify(r.Context(), q, notificationParams{
+				UserID:  mentionedID,
+				Type:    notificationTypeMention,
+				ChirpID: uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+				ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+		}
+		return nil
 	})
 	if err != nil {
+		cfg.releaseChirps(userID)
 		respondWithError(w, http.StatusInternalServerError, "Failed to create chirp", err)
 		return
 	}
+	cfg.publishEvents(r.Context(), events...)
+	cfg.metrics.chirpsCreated.WithLabelValues(chirpSourceAPI).Inc()
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
+	respondWithJSON(w, http.StatusCreated, chirp)
+}
+
+// chirpLengthError reports a body that is over its length limit, as counted
+// by chirptext.Length.
+type chirpLengthError struct {
+	Length int
+	Limit  int
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLength = 140
-	if len(body) > maxChirpLength {
-		return body, fmt.Errorf("chirp exceeds maximum length of %d characters", maxChirpLength)
+func (e *chirpLengthError) Error() string {
+	return fmt.Sprintf("body is %d characters long, exceeding the maximum of %d", e.Length, e.Limit)
+}
+
+// validateChirp normalizes a chirp body, checks its length and runs it
+// through the content filter, returning the body with masked words
+// replaced. Bodies matching a reject rule are refused.
+func (cfg *apiConfig) validateChirp(body string, maxChirpLength int) (string, error) {
+	body, err := chirptext.Normalize(body)
+	if err != nil {
+		return "", err
+	}
+
+	if length := chirptext.Length(body); length > maxChirpLength {
+		return body, &chirpLengthError{Length: length, Limit: maxChirpLength}
+	}
+
+	result := cfg.contentFilter.Load().Apply(body)
+	if result.Rejected() {
+		return body, errors.New("chirp contains disallowed content")
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	return result.Text, nil
+}
+
+// respondWithInvalidChirp responds to a body refused by validateChirp,
+// including the counted length and the limit when it was too long.
+func respondWithInvalidChirp(w http.ResponseWriter, err error) {
+	type lengthErrorResponse struct {
+		Error     string `json:"error"`
+		Length    int    `json:"length"`
+		MaxLength int    `json:"max_length"`
 	}
-	cleanedBody := getCleanedBody(body, badWords)
 
-	return cleanedBody, nil
+	var lengthErr *chirpLengthError
+	if errors.As(err, &lengthErr) {
+		respondWithJSON(w, http.StatusBadRequest, lengthErrorResponse{
+			Error:     lengthErr.Error(),
+			Length:    lengthErr.Length,
+			MaxLength: lengthErr.Limit,
+		})
+		return
+	}
+	if errors.Is(err, chirptext.ErrInvalidUTF8) {
+		respondWithError(w, http.StatusBadRequest, "Body is not valid UTF-8", err)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Invalid chirp body", err)
 }
 
-func getCleanedBody(body string, badWords map[string]struct{}) string {
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		loweredWord := strings.ToLower(word)
-		if _, ok := badWords[loweredWord]; ok {
-			words[i] = "****"
+const (
+	chirpVisibilityPublic    = "public"
+	chirpVisibilityFollowers = "followers"
+	chirpVisibilityUnlisted  = "unlisted"
+	chirpVisibilityPrivate   = "private"
+)
+
+// validateVisibility checks a requested visibility level, defaulting to
+// public when none is given.
+func validateVisibility(visibility string) (string, error) {
+	switch visibility {
+	case "":
+		return chirpVisibilityPublic, nil
+	case chirpVisibilityPublic, chirpVisibilityFollowers, chirpVisibilityUnlisted, chirpVisibilityPrivate:
+		return visibility, nil
+	default:
+		return "", fmt.Errorf("visibility must be one of %q, %q, %q or %q",
+			chirpVisibilityPublic, chirpVisibilityFollowers, chirpVisibilityUnlisted, chirpVisibilityPrivate)
+	}
+}
+
+type pollRequest struct {
+	Options   []string  `json:"options"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type validatedPoll struct {
+	Options   []string
+	ExpiresAt time.Time
+}
+
+func (cfg *apiConfig) validatePoll(poll pollRequest) (validatedPoll, error) {
+	const (
+		minPollOptions      = 2
+		maxPollOptions      = 4
+		maxPollOptionLength = 25
+		minPollDuration     = 5 * time.Minute
+		maxPollDuration     = 7 * 24 * time.Hour
+	)
+
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return validatedPoll{}, fmt.Errorf("poll must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+
+	seen := make(map[string]struct{}, len(poll.Options))
+	options := make([]string, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		cleaned, err := cfg.validateChirp(strings.TrimSpace(option), maxPollOptionLength)
+		if err != nil {
+			var lengthErr *chirpLengthError
+			if errors.As(err, &lengthErr) {
+				return validatedPoll{}, fmt.Errorf("poll option exceeds maximum length of %d characters", maxPollOptionLength)
+			}
+			return validatedPoll{}, err
+		}
+		cleaned = strings.TrimSpace(cleaned)
+		if cleaned == "" {
+			return validatedPoll{}, fmt.Errorf("poll options must not be empty")
 		}
+		key := strings.ToLower(cleaned)
+		if _, ok := seen[key]; ok {
+			return validatedPoll{}, fmt.Errorf("poll options must be unique")
+		}
+		seen[key] = struct{}{}
+		options = append(options, cleaned)
+	}
+
+	duration := time.Until(poll.ExpiresAt)
+	if duration < minPollDuration || duration > maxPollDuration {
+		return validatedPoll{}, fmt.Errorf("poll must expire between %s and %s from now", minPollDuration, maxPollDuration)
 	}
-	cleaned := strings.Join(words, " ")
-	return cleaned
+
+	return validatedPoll{
+		Options:   options,
+		ExpiresAt: poll.ExpiresAt,
+	}, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -20,6 +22,14 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
 		return
 	}
+	if dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
+	if dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has already been deleted", nil)
+		return
+	}
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -27,7 +37,7 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(r, token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
 		return
@@ -38,14 +48,93 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     chirpID,
-		UserID: userID,
+	var event database.OutboxEvent
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:     chirpID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		event, err = recordEvent(r.Context(), q, eventChirpDeleted, userID, dbChirp.Visibility == chirpVisibilityPublic, chirpDeletedEvent{
+			ID:         chirpID,
+			UserID:     userID,
+			Visibility: dbChirp.Visibility,
+		})
+		return err
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to delete chirp", err)
 		return
 	}
+	cfg.publishEvents(r.Context(), event)
 
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+		return
+	}
+	if dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
+
+	if dbChirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You are not authorized to restore this chirp", nil)
+		return
+	}
+
+	if !dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Chirp is not deleted", nil)
+		return
+	}
+	if dbChirp.ModeratedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Chirp was removed by a moderator", nil)
+		return
+	}
+
+	restored, err := cfg.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:                   chirpID,
+		UserID:               userID,
+		RestoreWindowSeconds: cfg.chirpRestoreWindow.Seconds(),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusGone, "Restore window has expired", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to restore chirp", err)
+		return
+	}
+
+	chirps := []Chirp{chirpFromDB(restored)}
+	if err := cfg.attachPolls(r.Context(), chirps, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"sort"
 
@@ -9,8 +10,13 @@ import (
 )
 
 func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
 	var dbChirps []database.Chirp
-	var err error
 
 	authorIdStr := r.URL.Query().Get("author_id")
 	sortOrder := r.URL.Query().Get("sort")
@@ -22,9 +28,12 @@ func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request
 			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
 			return
 		}
-		dbChirps, err = cfg.db.GetChirpsByUser(r.Context(), userUuid)
+		dbChirps, err = cfg.db.GetChirpsByUser(r.Context(), database.GetChirpsByUserParams{
+			UserID:   userUuid,
+			ViewerID: viewerID,
+		})
 	} else {
-		dbChirps, err = cfg.db.GetAllChirps(r.Context())
+		dbChirps, err = cfg.db.GetAllChirps(r.Context(), viewerID)
 	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Unable to get chirps", err)
@@ -41,13 +50,12 @@ func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request
 
 	chirps := make([]Chirp, 0, len(dbChirps))
 	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
-		})
+		chirps = append(chirps, chirpFromDB(dbChirp))
+	}
+
+	if err := cfg.attachPolls(r.Context(), chirps, viewerID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get polls", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, chirps)
@@ -60,19 +68,82 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
 	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
 		return
 	}
+	if dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
 
-	chirp := Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+	// Chirps the viewer may not see are reported as missing rather than
+	// forbidden so that their existence isn't revealed.
+	canView, err := cfg.canViewChirp(r.Context(), dbChirp, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get chirp", err)
+		return
+	}
+	if !canView {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
+	if dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	chirps := []Chirp{chirpFromDB(dbChirp)}
+	if err := cfg.attachPolls(r.Context(), chirps, viewerID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+// canViewChirp reports whether viewerID (uuid.Nil for anonymous callers) may
+// read dbChirp given its visibility, any block between viewer and author and
+// whether the author is suspended or banned.
+func (cfg *apiConfig) canViewChirp(ctx context.Context, dbChirp database.Chirp, viewerID uuid.UUID) (bool, error) {
+	if viewerID != uuid.Nil && viewerID == dbChirp.UserID {
+		return true, nil
 	}
 
-	respondWithJSON(w, http.StatusOK, chirp)
+	restricted, err := cfg.isAuthorRestricted(ctx, dbChirp.UserID)
+	if err != nil || restricted {
+		return false, err
+	}
+
+	if viewerID != uuid.Nil {
+		blocked, err := cfg.db.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+			UserID:   viewerID,
+			OtherIds: []uuid.UUID{dbChirp.UserID},
+		})
+		if err != nil || blocked {
+			return false, err
+		}
+	}
+
+	switch dbChirp.Visibility {
+	case chirpVisibilityPublic, chirpVisibilityUnlisted:
+		return true, nil
+	case chirpVisibilityFollowers:
+		if viewerID == uuid.Nil {
+			return false, nil
+		}
+		return cfg.db.IsFollowing(ctx, database.IsFollowingParams{
+			FollowerID: viewerID,
+			FolloweeID: dbChirp.UserID,
+		})
+	default:
+		return false, nil
+	}
 }
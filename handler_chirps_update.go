@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body string `json:"body"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	limits, err := cfg.limitsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load entitlements", err)
+		return
+	}
+	if !limits.CanEditChirps {
+		respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red", nil)
+		return
+	}
+
+	if err := decodeTextRequest(r, &req); err != nil {
+		respondWithInvalidPayload(w, err)
+		return
+	}
+
+	cleanedBody, err := cfg.validateChirp(req.Body, limits.MaxChirpLength)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid || dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+		return
+	}
+
+	if dbChirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You are not authorized to edit this chirp", nil)
+		return
+	}
+
+	var updated database.Chirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// Keep the previous body so edits remain auditable.
+		err := q.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+			ChirpID: dbChirp.ID,
+			Body:    dbChirp.Body,
+		})
+		if err != nil {
+			return err
+		}
+
+		updated, err = q.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			Body:   cleanedBody,
+			ID:     chirpID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		return cfg.flagChirpForReview(r.Context(), q, chirpFromDB(updated))
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to update chirp", err)
+		return
+	}
+
+	chirps := []Chirp{chirpFromDB(updated)}
+	if err := cfg.attachPolls(r.Context(), chirps, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	maxMessageLength       = 1000
+	maxConversationMembers = 10
+)
+
+type Conversation struct {
+	ID          uuid.UUID   `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	IsGroup     bool        `json:"is_group"`
+	MemberIDs   []uuid.UUID `json:"member_ids"`
+	UnreadCount int64       `json:"unread_count"`
+}
+
+type Message struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       *uuid.UUID `json:"sender_id"`
+	Body           string     `json:"body"`
+}
+
+// messageAAD binds a message's ciphertext to its conversation and ID, so it
+// can't be moved to another conversation in the database.
+func messageAAD(conversationID, messageID uuid.UUID) []byte {
+	return []byte(conversationID.String() + ":" + messageID.String())
+}
+
+func (cfg *apiConfig) messageFromDB(m database.Message) (Message, error) {
+	body, err := cfg.messageKeys.Decrypt(m.KeyID, m.Ciphertext, messageAAD(m.ConversationID, m.ID))
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		Body:           string(body),
+	}
+	if m.SenderID.Valid {
+		msg.SenderID = &m.SenderID.UUID
+	}
+	return msg, nil
+}
+
+// directKey identifies the 1:1 conversation between two users regardless of
+// who started it.
+func directKey(a, b uuid.UUID) string {
+	ids := []string{a.String(), b.String()}
+	slices.Sort(ids)
+	return strings.Join(ids, ":")
+}
+
+// conversationMember authenticates the caller and loads the conversation in
+// the path, responding with an error and returning false if the caller isn't
+// a member.
+func (cfg *apiConfig) conversationMember(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Conversation, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	conversation, err := cfg.db.GetConversationForMember(r.Context(), database.GetConversationForMemberParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Conversation not found", err)
+			return uuid.Nil, database.Conversation{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get conversation", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+	return userID, conversation, true
+}
+
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	var others []uuid.UUID
+	for _, id := range req.MemberIDs {
+		if id != userID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs at least one other member", nil)
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, "Too many conversation members", nil)
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't message one or more of these users", nil)
+		return
+	}
+
+	var key sql.NullString
+	if len(others) == 1 {
+		key = sql.NullString{String: directKey(userID, others[0]), Valid: true}
+	}
+
+	status := http.StatusCreated
+	var conversation database.Conversation
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		conversation, err = q.CreateConversation(r.Context(), database.CreateConversationParams{
+			CreatedBy: uuid.NullUUID{UUID: userID, Valid: true},
+			IsGroup:   len(others) > 1,
+			DirectKey: key,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The pair already has a 1:1 conversation.
+			status = http.StatusOK
+			conversation, err = q.GetConversationByDirectKey(r.Context(), key)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, memberID := range append([]uuid.UUID{userID}, others...) {
+			err = q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         memberID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to create conversation", err)
+		return
+	}
+
+	respondWithJSON(w, status, Conversation{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		IsGroup:   conversation.IsGroup,
+		MemberIDs: append([]uuid.UUID{userID}, others...),
+	})
+}
+
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 100
+	)
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := cfg.db.GetConversationsForUser(r.Context(), database.GetConversationsForUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get conversations", err)
+		return
+	}
+
+	conversations := make([]Conversation, 0, len(rows))
+	byID := make(map[uuid.UUID]int, len(rows))
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = len(conversations)
+		ids = append(ids, row.ID)
+		conversations = append(conversations, Conversation{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			IsGroup:     row.IsGroup,
+			MemberIDs:   []uuid.UUID{},
+			UnreadCount: row.UnreadCount,
+		})
+	}
+
+	if len(ids) > 0 {
+		members, err := cfg.db.GetConversationMemberIDs(r.Context(), ids)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Unable to get conversation members", err)
+			return
+		}
+		for _, member := range members {
+			c := &conversations[byID[member.ConversationID]]
+			c.MemberIDs = append(c.MemberIDs, member.UserID)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+func (cfg *apiConfig) handlerGetMessages(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 100
+	)
+
+	if cfg.messageKeys == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Direct messages are not configured", nil)
+		return
+	}
+
+	_, conversation, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	var beforeID uuid.NullUUID
+	if before := r.URL.Query().Get("before"); before != "" {
+		id, err := uuid.Parse(before)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before cursor", err)
+			return
+		}
+		beforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	dbMessages, err := cfg.db.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversation.ID,
+		BeforeID:       beforeID,
+		MaxResults:     int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get messages", err)
+		return
+	}
+
+	messages := make([]Message, 0, len(dbMessages))
+	for _, dbMessage := range dbMessages {
+		msg, err := cfg.messageFromDB(dbMessage)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Unable to decrypt message", err)
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+func (cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body string `json:"body"`
+	}
+
+	if cfg.messageKeys == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Direct messages are not configured", nil)
+		return
+	}
+
+	userID, conversation, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decodeTextRequest(r, &req); err != nil {
+		respondWithInvalidPayload(w, err)
+		return
+	}
+
+	cleanedBody, err := cfg.validateChirp(req.Body, maxMessageLength)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+	if strings.TrimSpace(cleanedBody) == "" {
+		respondWithError(w, http.StatusBadRequest, "Message body must not be empty", nil)
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedInConversation(r.Context(), database.IsBlockedInConversationParams{
+		UserID:         userID,
+		ConversationID: conversation.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't message this conversation", nil)
+		return
+	}
+
+	messageID := uuid.New()
+	keyID, ciphertext, err := cfg.messageKeys.Encrypt([]byte(cleanedBody), messageAAD(conversation.ID, messageID))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to encrypt message", err)
+		return
+	}
+
+	var dbMessage database.Message
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbMessage, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ID:             messageID,
+			ConversationID: conversation.ID,
+			SenderID:       uuid.NullUUID{UUID: userID, Valid: true},
+			KeyID:          keyID,
+			Ciphertext:     ciphertext,
+		})
+		if err != nil {
+			return err
+		}
+		return q.TouchConversation(r.Context(), conversation.ID)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to send message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Message{
+		ID:             dbMessage.ID,
+		CreatedAt:      dbMessage.CreatedAt,
+		ConversationID: dbMessage.ConversationID,
+		SenderID:       &userID,
+		Body:           cleanedBody,
+	})
+}
+
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark conversation as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
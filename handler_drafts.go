@@ -0,0 +1,361 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	chirpStatusDraft     = "draft"
+	chirpStatusScheduled = "scheduled"
+	chirpStatusPublished = "published"
+)
+
+type Draft struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Body       string     `json:"body"`
+	Visibility string     `json:"visibility"`
+	Status     string     `json:"status"`
+	PublishAt  *time.Time `json:"publish_at"`
+}
+
+type draftRequest struct {
+	Body       string     `json:"body"`
+	Visibility string     `json:"visibility"`
+	PublishAt  *time.Time `json:"publish_at"`
+}
+
+func draftFromDB(dbChirp database.Chirp) Draft {
+	draft := Draft{
+		ID:         dbChirp.ID,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+		UserID:     dbChirp.UserID,
+		Body:       dbChirp.Body,
+		Visibility: dbChirp.Visibility,
+		Status:     dbChirp.Status,
+	}
+	if dbChirp.PublishAt.Valid {
+		draft.PublishAt = &dbChirp.PublishAt.Time
+	}
+	return draft
+}
+
+// draftStatus validates the requested publish time and returns the status
+// and publish_at value to store: a draft when no time is given, otherwise a
+// chirp scheduled for that time.
+func draftStatus(publishAt *time.Time) (string, sql.NullTime, error) {
+	if publishAt == nil {
+		return chirpStatusDraft, sql.NullTime{}, nil
+	}
+	if !publishAt.After(time.Now()) {
+		return "", sql.NullTime{}, errors.New("publish_at must be in the future")
+	}
+	return chirpStatusScheduled, sql.NullTime{Time: *publishAt, Valid: true}, nil
+}
+
+func (cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	var req draftRequest
+	if err := decodeTextRequest(r, &req); err != nil {
+		respondWithInvalidPayload(w, err)
+		return
+	}
+
+	limits, err := cfg.limitsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load entitlements", err)
+		return
+	}
+
+	cleanedBody, err := cfg.validateChirp(req.Body, limits.MaxChirpLength)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+
+	visibility, err := validateVisibility(req.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid visibility", err)
+		return
+	}
+
+	status, publishAt, err := draftStatus(req.PublishAt)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	draft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		Body:       cleanedBody,
+		UserID:     userID,
+		Status:     status,
+		PublishAt:  publishAt,
+		Visibility: visibility,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, draftFromDB(draft))
+}
+
+func (cfg *apiConfig) handlerGetDrafts(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	statusFilter := r.URL.Query().Get("status")
+	if statusFilter != "" && statusFilter != chirpStatusDraft && statusFilter != chirpStatusScheduled {
+		respondWithError(w, http.StatusBadRequest, "Invalid status filter", nil)
+		return
+	}
+
+	dbDrafts, err := cfg.db.GetDraftsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get drafts", err)
+		return
+	}
+
+	drafts := make([]Draft, 0, len(dbDrafts))
+	for _, dbDraft := range dbDrafts {
+		if statusFilter != "" && dbDraft.Status != statusFilter {
+			continue
+		}
+		drafts = append(drafts, draftFromDB(dbDraft))
+	}
+
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	draft, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Draft not found", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draftFromDB(draft))
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	var req draftRequest
+	if err := decodeTextRequest(r, &req); err != nil {
+		respondWithInvalidPayload(w, err)
+		return
+	}
+
+	limits, err := cfg.limitsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load entitlements", err)
+		return
+	}
+
+	cleanedBody, err := cfg.validateChirp(req.Body, limits.MaxChirpLength)
+	if err != nil {
+		respondWithInvalidChirp(w, err)
+		return
+	}
+
+	visibility, err := validateVisibility(req.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid visibility", err)
+		return
+	}
+
+	status, publishAt, err := draftStatus(req.PublishAt)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	draft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		Body:       cleanedBody,
+		Status:     status,
+		PublishAt:  publishAt,
+		Visibility: visibility,
+		ID:         draftID,
+		UserID:     userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Draft not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to update draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draftFromDB(draft))
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete draft", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	limits, err := cfg.limitsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load entitlements", err)
+		return
+	}
+
+	var chirp Chirp
+	var event database.OutboxEvent
+	var retryAfter time.Duration
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		dbChirp, err := q.PublishDraft(r.Context(), database.PublishDraftParams{
+			ID:     draftID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		// The limit is checked once the draft is known to exist, and the
+		// draft is left unpublished when it has been reached.
+		var ok bool
+		if ok, retryAfter = cfg.allowChirp(userID, limits); !ok {
+			return errChirpRateLimited
+		}
+		chirp = chirpFromDB(dbChirp)
+		event, err = recordChirpCreated(r.Context(), q, chirp)
+		if err != nil {
+			return err
+		}
+		return cfg.flagChirpForReview(r.Context(), q, chirp)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Draft not found", err)
+			return
+		}
+		if errors.Is(err, errChirpRateLimited) {
+			respondWithChirpRateLimit(w, retryAfter)
+			return
+		}
+		cfg.releaseChirps(userID)
+		respondWithError(w, http.StatusInternalServerError, "Failed to publish draft", err)
+		return
+	}
+	cfg.publishEvents(r.Context(), event)
+	cfg.metrics.chirpsCreated.WithLabelValues(chirpSourceDraft).Inc()
+
+	respondWithJSON(w, http.StatusOK, chirp)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+type DataExport struct {
+	ID                   uuid.UUID  `json:"id"`
+	CreatedAt            time.Time  `json:"created_at"`
+	Status               string     `json:"status"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+	DownloadURL          string     `json:"download_url,omitempty"`
+	DownloadURLExpiresAt *time.Time `json:"download_url_expires_at,omitempty"`
+}
+
+// dataExportFromDB converts an export, adding a signed download link once
+// its archive is ready.
+func (cfg *apiConfig) dataExportFromDB(export database.DataExport) DataExport {
+	resp := DataExport{
+		ID:          export.ID,
+		CreatedAt:   export.CreatedAt,
+		Status:      export.Status,
+		CompletedAt: nullTimePtr(export.CompletedAt),
+		ExpiresAt:   nullTimePtr(export.ExpiresAt),
+	}
+	if export.Status != dataExportCompleted || !export.ExpiresAt.Valid {
+		return resp
+	}
+
+	linkExpiresAt := time.Now().Add(cfg.exportLinkTTL).Truncate(time.Second)
+	if linkExpiresAt.After(export.ExpiresAt.Time) {
+		linkExpiresAt = export.ExpiresAt.Time
+	}
+	path := fmt.Sprintf("/api/exports/%s/download", export.ID)
+	expires := strconv.FormatInt(linkExpiresAt.Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {auth.SignURL(cfg.exportSigningKey, path, expires)},
+	}
+	resp.DownloadURL = path + "?" + query.Encode()
+	resp.DownloadURLExpiresAt = &linkExpiresAt
+	return resp
+}
+
+func (cfg *apiConfig) handlerCreateDataExport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	export, err := cfg.db.CreateDataExport(r.Context(), userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			respondWithError(w, http.StatusConflict, "An export is already in progress", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to start export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+}
+
+func (cfg *apiConfig) handlerGetDataExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	export, err := cfg.db.GetDataExport(r.Context(), database.GetDataExportParams{
+		ID:     exportID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Export not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cfg.dataExportFromDB(export))
+}
+
+// handlerDownloadDataExport serves an export archive. The signed link is
+// the only credential, so it can be opened directly in a browser.
+func (cfg *apiConfig) handlerDownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	query := r.URL.Query()
+	if err := auth.VerifySignedURL(cfg.exportSigningKey, r.URL.Path, query.Get("expires"), query.Get("signature"), time.Now()); err != nil {
+		respondWithError(w, http.StatusForbidden, "Invalid or expired download link", err)
+		return
+	}
+
+	data, err := cfg.db.GetDataExportArchive(r.Context(), exportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Export not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get export", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, exportID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		loggerFor(w).Error("Error writing export", "export_id", exportID, "error", err)
+	}
+}
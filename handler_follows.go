@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "You cannot follow yourself", nil)
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedBetween(r.Context(), database.IsBlockedBetweenParams{
+		UserID:   userID,
+		OtherIds: []uuid.UUID{followeeID},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You cannot follow this user", nil)
+		return
+	}
+
+	var events []database.OutboxEvent
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		followed, err := q.FollowUser(r.Context(), database.FollowUserParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+		if err != nil || followed == 0 {
+			return err
+		}
+		event, err := notify(r.Context(), q, notificationParams{
+			UserID:  followeeID,
+			Type:    notificationTypeFollow,
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+		return nil
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to follow user", err)
+		return
+	}
+	cfg.publishEvents(r.Context(), events...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	err = cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unfollow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/archive"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+// maxChirpImportSize caps the size of an uploaded archive.
+const maxChirpImportSize = 10 << 20
+
+type ChirpImport struct {
+	ID          uuid.UUID          `json:"id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Status      string             `json:"status"`
+	Position    int32              `json:"lines_processed"`
+	Imported    int32              `json:"imported"`
+	Failed      int32              `json:"failed"`
+	Error       *string            `json:"error,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	Errors      []ChirpImportError `json:"errors"`
+}
+
+type ChirpImportError struct {
+	Line  int32  `json:"line"`
+	Error string `json:"error"`
+}
+
+func chirpImportFromDB(imp database.ChirpImport, itemErrors []database.ChirpImportError) ChirpImport {
+	resp := ChirpImport{
+		ID:          imp.ID,
+		CreatedAt:   imp.CreatedAt,
+		Status:      imp.Status,
+		Position:    imp.Position,
+		Imported:    imp.ImportedCount,
+		Failed:      imp.FailedCount,
+		CompletedAt: nullTimePtr(imp.CompletedAt),
+		Errors:      make([]ChirpImportError, 0, len(itemErrors)),
+	}
+	if imp.Error.Valid {
+		resp.Error = &imp.Error.String
+	}
+	for _, e := range itemErrors {
+		resp.Errors = append(resp.Errors, ChirpImportError{Line: e.Line, Error: e.Error})
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handlerCreateChirpImport(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChirpImportSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Archive is too large", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Unable to read archive", err)
+		return
+	}
+	if len(data) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Archive is empty", nil)
+		return
+	}
+	if _, err := archive.OpenChirps(data); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid archive", err)
+		return
+	}
+
+	var imp database.ChirpImport
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		imp, err = q.CreateChirpImport(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		return q.CreateChirpImportUpload(r.Context(), database.CreateChirpImportUploadParams{
+			ImportID: imp.ID,
+			Data:     data,
+		})
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+			respondWithError(w, http.StatusConflict, "An import is already in progress", nil)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to start import", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, chirpImportFromDB(imp, nil))
+}
+
+func (cfg *apiConfig) handlerGetChirpImport(w http.ResponseWriter, r *http.Request) {
+	importID, err := uuid.Parse(r.PathValue("importID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid import ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	imp, err := cfg.db.GetChirpImport(r.Context(), database.GetChirpImportParams{
+		ID:     importID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Import not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get import", err)
+		return
+	}
+
+	itemErrors, err := cfg.db.GetChirpImportErrors(r.Context(), imp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get import", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpImportFromDB(imp, itemErrors))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid || dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+		return
+	}
+
+	canView, err := cfg.canViewChirp(r.Context(), dbChirp, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get chirp", err)
+		return
+	}
+	if !canView {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
+
+	var events []database.OutboxEvent
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		liked, err := q.LikeChirp(r.Context(), database.LikeChirpParams{
+			UserID:  userID,
+			ChirpID: chirpID,
+		})
+		if err != nil || liked == 0 || dbChirp.UserID == userID {
+			return err
+		}
+		event, err := notify(r.Context(), q, notificationParams{
+			UserID:  dbChirp.UserID,
+			Type:    notificationTypeLike,
+			ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+			ActorID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to like chirp", err)
+		return
+	}
+	cfg.publishEvents(r.Context(), events...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find token: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unlike chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
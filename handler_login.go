@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -40,16 +41,33 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.metrics.failedLogins.Inc()
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password", nil)
 		return
 	}
 
 	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
+		cfg.metrics.failedLogins.Inc()
 		respondWithError(w, http.StatusUnauthorized, "Invalid email or password", nil)
 		return
 	}
 
+	// Logging in during the grace period cancels a pending account
+	// deletion, but only once the login is known to be allowed: a banned or
+	// suspended user's deletion goes ahead.
+	pendingDeletion := user.DeletionRequestedAt.Valid
+	user.DeletionRequestedAt = sql.NullTime{}
+	if respondIfRestricted(w, user) {
+		return
+	}
+	if pendingDeletion {
+		if err := cfg.db.CancelUserDeletion(r.Context(), user.ID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to restore account", err)
+			return
+		}
+	}
+
 	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create token", err)
@@ -84,5 +102,6 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refreshToken,
 	}
 
+	cfg.metrics.logins.Inc()
 	respondWithJSON(w, http.StatusOK, response)
 }
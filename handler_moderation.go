@@ -0,0 +1,484 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	moderationActionRemoveChirp = "remove_chirp"
+	moderationActionWarn        = "warn"
+	moderationActionSuspend     = "suspend"
+	moderationActionBan         = "ban"
+	moderationActionDismiss     = "dismiss"
+	moderationActionUnsuspend   = "unsuspend"
+	moderationActionUnban       = "unban"
+)
+
+// errOutranked is returned when a moderator acts against a user whose role
+// is equal to or higher than their own.
+var errOutranked = errors.New("user has an equal or higher role")
+
+type ModerationAction struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ModeratorID    *uuid.UUID `json:"moderator_id"`
+	ReportID       *uuid.UUID `json:"report_id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ChirpID        *uuid.UUID `json:"chirp_id"`
+	Action         string     `json:"action"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	resp := ModerationAction{
+		ID:          action.ID,
+		CreatedAt:   action.CreatedAt,
+		ModeratorID: nullUUIDPtr(action.ModeratorID),
+		ReportID:    nullUUIDPtr(action.ReportID),
+		UserID:      action.UserID,
+		ChirpID:     nullUUIDPtr(action.ChirpID),
+		Action:      action.Action,
+		Reason:      action.Reason,
+	}
+	if action.SuspendedUntil.Valid {
+		resp.SuspendedUntil = &action.SuspendedUntil.Time
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handlerListReports(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 500
+	)
+
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", reportStatusOpen, reportStatusClaimed, reportStatusResolved:
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid status filter", nil)
+		return
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	dbReports, err := cfg.db.GetReports(r.Context(), database.GetReportsParams{
+		Status:     status,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get reports", err)
+		return
+	}
+
+	reports := make([]Report, 0, len(dbReports))
+	for _, report := range dbReports {
+		reports = append(reports, reportFromDB(report))
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+func (cfg *apiConfig) handlerGetReport(w http.ResponseWriter, r *http.Request) {
+	type reportResponse struct {
+		Report
+		Actions []ModerationAction `json:"actions"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return
+	}
+
+	dbActions, err := cfg.db.GetModerationActionsForReport(r.Context(), uuid.NullUUID{UUID: reportID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get moderation actions", err)
+		return
+	}
+
+	resp := reportResponse{
+		Report:  reportFromDB(report),
+		Actions: make([]ModerationAction, 0, len(dbActions)),
+	}
+	for _, action := range dbActions {
+		resp.Actions = append(resp.Actions, moderationActionFromDB(action))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerClaimReport assigns a report to the calling moderator so others
+// don't work on it at the same time.
+func (cfg *apiConfig) handlerClaimReport(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.ClaimReport(r.Context(), database.ClaimReportParams{
+		ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ID:          reportID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.respondWithReportConflict(w, r, reportID)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to claim report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+// respondWithReportConflict explains why a report couldn't be claimed or
+// resolved by the caller.
+func (cfg *apiConfig) respondWithReportConflict(w http.ResponseWriter, r *http.Request, reportID uuid.UUID) {
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return
+	}
+	if report.Status == reportStatusResolved {
+		respondWithError(w, http.StatusConflict, "Report has already been resolved", nil)
+		return
+	}
+	respondWithError(w, http.StatusConflict, "Report is claimed by another moderator", nil)
+}
+
+// handlerResolveReport applies a moderation action to the reported user or
+// chirp, records who took it and why, and closes the report.
+func (cfg *apiConfig) handlerResolveReport(w http.ResponseWriter, r *http.Request) {
+	type resolveRequest struct {
+		Action         string     `json:"action"`
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required", nil)
+		return
+	}
+
+	var suspendedUntil sql.NullTime
+	switch req.Action {
+	case moderationActionRemoveChirp, moderationActionWarn, moderationActionBan, moderationActionDismiss:
+	case moderationActionSuspend:
+		if req.SuspendedUntil == nil || !req.SuspendedUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+			return
+		}
+		suspendedUntil = sql.NullTime{Time: *req.SuspendedUntil, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid action", nil)
+		return
+	}
+
+	moderatorID := uuid.NullUUID{UUID: moderator.ID, Valid: true}
+	errNoChirp := errors.New("report has no chirp to remove")
+
+	var resp struct {
+		Report
+		Action ModerationAction `json:"action"`
+	}
+	var events []database.OutboxEvent
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		report, err := q.ResolveReport(r.Context(), database.ResolveReportParams{
+			ModeratorID: moderatorID,
+			Resolution:  sql.NullString{String: req.Action, Valid: true},
+			ID:          reportID,
+		})
+		if err != nil {
+			return err
+		}
+		resp.Report = reportFromDB(report)
+
+		switch req.Action {
+		case moderationActionRemoveChirp:
+			if !report.ChirpID.Valid {
+				return errNoChirp
+			}
+			before, err := q.GetChirp(r.Context(), report.ChirpID.UUID)
+			if err != nil {
+				return err
+			}
+			if _, err := q.RemoveChirp(r.Context(), before.ID); err != nil {
+				return err
+			}
+			if !before.DeletedAt.Valid {
+				event, err := recordEvent(r.Context(), q, eventChirpDeleted, before.UserID, before.Visibility == chirpVisibilityPublic, chirpDeletedEvent{
+					ID:         before.ID,
+					UserID:     before.UserID,
+					Visibility: before.Visibility,
+				})
+				if err != nil {
+					return err
+				}
+				events = append(events, event)
+			}
+		case moderationActionSuspend, moderationActionBan:
+			target, err := q.GetUserByID(r.Context(), report.UserID)
+			if err != nil {
+				return err
+			}
+			if !outranks(moderator.Role, target.Role) {
+				return errOutranked
+			}
+			event, err := setAccountRestriction(r.Context(), q, report.UserID, req.Action, suspendedUntil)
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+		}
+
+		action, err := q.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ModeratorID:    moderatorID,
+			ReportID:       uuid.NullUUID{UUID: report.ID, Valid: true},
+			UserID:         report.UserID,
+			ChirpID:        report.ChirpID,
+			Action:         req.Action,
+			Reason:         req.Reason,
+			SuspendedUntil: suspendedUntil,
+		})
+		if err != nil {
+			return err
+		}
+		resp.Action = moderationActionFromDB(action)
+
+		if req.Action == moderationActionDismiss {
+			return nil
+		}
+		event, err := notify(r.Context(), q, notificationParams{
+			UserID:   report.UserID,
+			Type:     notificationTypeModeration,
+			ChirpID:  report.ChirpID,
+			GroupKey: notificationTypeModeration + ":" + action.ID.String(),
+			Data: moderationNotification{
+				Action:         req.Action,
+				Reason:         req.Reason,
+				SuspendedUntil: resp.Action.SuspendedUntil,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			cfg.respondWithReportConflict(w, r, reportID)
+		case errors.Is(err, errNoChirp):
+			respondWithError(w, http.StatusConflict, "The report has no chirp to remove", nil)
+		case errors.Is(err, errOutranked):
+			respondWithError(w, http.StatusForbidden, "You cannot moderate a user with an equal or higher role", nil)
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Failed to resolve report", err)
+		}
+		return
+	}
+	cfg.publishEvents(r.Context(), events...)
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerGetUserModerationActions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	dbActions, err := cfg.db.GetModerationActionsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get moderation actions", err)
+		return
+	}
+
+	actions := make([]ModerationAction, 0, len(dbActions))
+	for _, action := range dbActions {
+		actions = append(actions, moderationActionFromDB(action))
+	}
+	respondWithJSON(w, http.StatusOK, actions)
+}
+
+// handlerModerateUser suspends, unsuspends, bans or unbans a user outside
+// of a report. The action to take comes from the route.
+func (cfg *apiConfig) handlerModerateUser(action string) http.HandlerFunc {
+	type moderateRequest struct {
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		moderator, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+		if !ok {
+			return
+		}
+
+		userID, err := uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+		if userID == moderator.ID {
+			respondWithError(w, http.StatusBadRequest, "You cannot moderate yourself", nil)
+			return
+		}
+
+		target, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusNotFound, "User not found", nil)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Unable to load user", err)
+			return
+		}
+		if !outranks(moderator.Role, target.Role) {
+			respondWithError(w, http.StatusForbidden, "You cannot moderate a user with an equal or higher role", nil)
+			return
+		}
+
+		var req moderateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+			return
+		}
+
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			respondWithError(w, http.StatusBadRequest, "A reason is required", nil)
+			return
+		}
+
+		var suspendedUntil sql.NullTime
+		if action == moderationActionSuspend {
+			if req.SuspendedUntil == nil || !req.SuspendedUntil.After(time.Now()) {
+				respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+				return
+			}
+			suspendedUntil = sql.NullTime{Time: *req.SuspendedUntil, Valid: true}
+		}
+
+		var resp ModerationAction
+		var events []database.OutboxEvent
+		err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+			event, err := setAccountRestriction(r.Context(), q, userID, action, suspendedUntil)
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+
+			dbAction, err := q.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+				ModeratorID:    uuid.NullUUID{UUID: moderator.ID, Valid: true},
+				UserID:         userID,
+				Action:         action,
+				Reason:         req.Reason,
+				SuspendedUntil: suspendedUntil,
+			})
+			if err != nil {
+				return err
+			}
+			resp = moderationActionFromDB(dbAction)
+
+			if action != moderationActionSuspend && action != moderationActionBan {
+				return nil
+			}
+			event, err = notify(r.Context(), q, notificationParams{
+				UserID:   userID,
+				Type:     notificationTypeModeration,
+				GroupKey: notificationTypeModeration + ":" + dbAction.ID.String(),
+				Data: moderationNotification{
+					Action:         action,
+					Reason:         req.Reason,
+					SuspendedUntil: resp.SuspendedUntil,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+			return nil
+		})
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				respondWithError(w, http.StatusNotFound, "User not found", nil)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Failed to moderate user", err)
+			return
+		}
+		cfg.publishEvents(r.Context(), events...)
+
+		respondWithJSON(w, http.StatusOK, resp)
+	}
+}
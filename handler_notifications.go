@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 200
+	)
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	var unreadOnly bool
+	if unread := r.URL.Query().Get("unread"); unread != "" {
+		unreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid unread filter", err)
+			return
+		}
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := cfg.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: unreadOnly,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get notifications", err)
+		return
+	}
+
+	notifications := make([]Notification, 0, len(rows))
+	for _, row := range rows {
+		notifications = append(notifications, notificationFromDB(database.Notification{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+			UserID:        row.UserID,
+			Type:          row.Type,
+			GroupKey:      row.GroupKey,
+			ChirpID:       row.ChirpID,
+			LatestActorID: row.LatestActorID,
+			Data:          row.Data,
+			ReadAt:        row.ReadAt,
+		}, row.ActorCount))
+	}
+
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	// Marking an already read notification succeeds, so the call is
+	// idempotent.
+	_, err = cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark notification as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	// An empty body marks every notification as read.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	marked, err := cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: userID,
+		Ids:    req.IDs,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark notifications as read", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Marked int64 `json:"marked"`
+	}{Marked: marked})
+}
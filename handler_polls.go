@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+type Poll struct {
+	ID            uuid.UUID    `json:"id"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	Expired       bool         `json:"expired"`
+	Options       []PollOption `json:"options"`
+	TotalVotes    *int64       `json:"total_votes,omitempty"`
+	VotedOptionID *uuid.UUID   `json:"voted_option_id,omitempty"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+// attachPolls loads the polls for the given chirps and sets their Poll
+// field. Vote tallies are only included once the viewer has voted or the
+// poll has expired.
+func (cfg *apiConfig) attachPolls(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbPolls, err := cfg.db.GetPollsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	if len(dbPolls) == 0 {
+		return nil
+	}
+
+	pollIDs := make([]uuid.UUID, 0, len(dbPolls))
+	for _, dbPoll := range dbPolls {
+		pollIDs = append(pollIDs, dbPoll.ID)
+	}
+
+	tallies, err := cfg.db.GetPollOptionTallies(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+
+	votes := make(map[uuid.UUID]uuid.UUID)
+	if viewerID != uuid.Nil {
+		dbVotes, err := cfg.db.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+			UserID:  viewerID,
+			PollIds: pollIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vote := range dbVotes {
+			votes[vote.PollID] = vote.OptionID
+		}
+	}
+
+	now := time.Now()
+	polls := make(map[uuid.UUID]*Poll, len(dbPolls))
+	pollsByID := make(map[uuid.UUID]*Poll, len(dbPolls))
+	for _, dbPoll := range dbPolls {
+		poll := &Poll{
+			ID:        dbPoll.ID,
+			ExpiresAt: dbPoll.ExpiresAt,
+			Expired:   !dbPoll.ExpiresAt.After(now),
+			Options:   []PollOption{},
+		}
+		var showResults bool
+		if optionID, ok := votes[dbPoll.ID]; ok {
+			poll.VotedOptionID = &optionID
+			showResults = true
+		}
+		if poll.Expired || showResults {
+			poll.TotalVotes = new(int64)
+		}
+		polls[dbPoll.ChirpID] = poll
+		pollsByID[dbPoll.ID] = poll
+	}
+
+	for _, tally := range tallies {
+		poll := pollsByID[tally.PollID]
+		option := PollOption{
+			ID:   tally.ID,
+			Text: tally.Text,
+		}
+		if poll.TotalVotes != nil {
+			count := tally.Votes
+			option.Votes = &count
+			*poll.TotalVotes += count
+		}
+		poll.Options = append(poll.Options, option)
+	}
+
+	for i := range chirps {
+		chirps[i].Poll = polls[chirps[i].ID]
+	}
+	return nil
+}
+
+// createPoll stores a validated poll and its options for a new chirp.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, req validatedPoll) (*Poll, error) {
+	dbPoll, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:   chirpID,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &Poll{
+		ID:        dbPoll.ID,
+		ExpiresAt: dbPoll.ExpiresAt,
+		Options:   make([]PollOption, 0, len(req.Options)),
+	}
+	for i, text := range req.Options {
+		option, err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   dbPoll.ID,
+			Position: int32(i),
+			Text:     text,
+		})
+		if err != nil {
+			return nil, err
+		}
+		poll.Options = append(poll.Options, PollOption{
+			ID:   option.ID,
+			Text: option.Text,
+		})
+	}
+	return poll, nil
+}
+
+func (cfg *apiConfig) handlerVotePoll(w http.ResponseWriter, r *http.Request) {
+	type voteRequest struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	var req voteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid || dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+		return
+	}
+
+	canView, err := cfg.canViewChirp(r.Context(), dbChirp, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get chirp", err)
+		return
+	}
+	if !canView {
+		respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+		return
+	}
+
+	dbPoll, err := cfg.db.GetPollByChirpID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp has no poll", err)
+		return
+	}
+
+	if !dbPoll.ExpiresAt.After(time.Now()) {
+		respondWithError(w, http.StatusForbidden, "Poll has expired", nil)
+		return
+	}
+
+	inserted, err := cfg.db.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		UserID:   userID,
+		OptionID: req.OptionID,
+		PollID:   dbPoll.ID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				respondWithError(w, http.StatusConflict, "You have already voted in this poll", nil)
+				return
+			case "23503": // foreign_key_violation
+				respondWithError(w, http.StatusBadRequest, "Invalid poll option", nil)
+				return
+			}
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to record vote", err)
+		return
+	}
+	if inserted == 0 {
+		respondWithError(w, http.StatusForbidden, "Poll has expired", nil)
+		return
+	}
+
+	chirps := []Chirp{chirpFromDB(dbChirp)}
+	if err := cfg.attachPolls(r.Context(), chirps, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to load poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirps[0].Poll)
+}
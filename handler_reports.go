@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const maxReportDetailsLength = 1000
+
+const (
+	reportStatusOpen     = "open"
+	reportStatusClaimed  = "claimed"
+	reportStatusResolved = "resolved"
+)
+
+var reportReasons = map[string]struct{}{
+	"spam":           {},
+	"harassment":     {},
+	"hate":           {},
+	"violence":       {},
+	"sexual":         {},
+	"misinformation": {},
+	"other":          {},
+}
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ClaimedBy  *uuid.UUID `json:"claimed_by"`
+	ClaimedAt  *time.Time `json:"claimed_at"`
+	ResolvedBy *uuid.UUID `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	Resolution *string    `json:"resolution"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func reportFromDB(report database.Report) Report {
+	resp := Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ReporterID: nullUUIDPtr(report.ReporterID),
+		UserID:     report.UserID,
+		ChirpID:    nullUUIDPtr(report.ChirpID),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		ClaimedBy:  nullUUIDPtr(report.ClaimedBy),
+		ResolvedBy: nullUUIDPtr(report.ResolvedBy),
+	}
+	if report.ClaimedAt.Valid {
+		resp.ClaimedAt = &report.ClaimedAt.Time
+	}
+	if report.ResolvedAt.Valid {
+		resp.ResolvedAt = &report.ResolvedAt.Time
+	}
+	if report.Resolution.Valid {
+		resp.Resolution = &report.Resolution.String
+	}
+	return resp
+}
+
+// handlerCreateReport flags a chirp, or a user when no chirp is given, for
+// moderator review.
+func (cfg *apiConfig) handlerCreateReport(w http.ResponseWriter, r *http.Request) {
+	type reportRequest struct {
+		ChirpID *uuid.UUID `json:"chirp_id"`
+		UserID  *uuid.UUID `json:"user_id"`
+		Reason  string     `json:"reason"`
+		Details string     `json:"details"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	reporterID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	var req reportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if _, ok := reportReasons[req.Reason]; !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid report reason", nil)
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if len(req.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Report details are too long", nil)
+		return
+	}
+
+	params := database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: reporterID, Valid: true},
+		Reason:     req.Reason,
+		Details:    req.Details,
+	}
+	switch {
+	case req.ChirpID != nil:
+		dbChirp, err := cfg.db.GetChirp(r.Context(), *req.ChirpID)
+		if err != nil || dbChirp.DeletedAt.Valid || dbChirp.Status != chirpStatusPublished {
+			respondWithError(w, http.StatusNotFound, "Chirp not found!", err)
+			return
+		}
+		canView, err := cfg.canViewChirp(r.Context(), dbChirp, reporterID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Unable to get chirp", err)
+			return
+		}
+		if !canView {
+			respondWithError(w, http.StatusNotFound, "Chirp not found!", nil)
+			return
+		}
+		if req.UserID != nil && *req.UserID != dbChirp.UserID {
+			respondWithError(w, http.StatusBadRequest, "user_id doesn't match the chirp's author", nil)
+			return
+		}
+		params.UserID = dbChirp.UserID
+		params.ChirpID = uuid.NullUUID{UUID: dbChirp.ID, Valid: true}
+	case req.UserID != nil:
+		params.UserID = *req.UserID
+	default:
+		respondWithError(w, http.StatusBadRequest, "A chirp_id or user_id is required", nil)
+		return
+	}
+
+	if params.UserID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You cannot report yourself", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), params)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				respondWithError(w, http.StatusConflict, "You have already reported this", nil)
+				return
+			case "23503": // foreign_key_violation
+				respondWithError(w, http.StatusNotFound, "User not found", nil)
+				return
+			}
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/broker"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	streamKeepaliveInterval = 15 * time.Second
+	streamReplayBatchSize   = 100
+	maxStreamReplay         = 1000
+)
+
+// streamedChirpEvents are the events sent on the public chirp stream.
+var streamedChirpEvents = []string{eventChirpCreated, eventChirpDeleted}
+
+// streamEventReset tells a client resuming from further back than the
+// replay window that events were skipped. Its ID is the latest event's, so
+// the client resumes from there if it reconnects.
+const streamEventReset = "reset"
+
+// errStreamReplayLimit closes a stream that has replayed maxStreamReplay
+// events, leaving the rest of the backlog to the next connection.
+var errStreamReplayLimit = errors.New("stream replay limit reached")
+
+func writeStreamEvent(w http.ResponseWriter, id int64, eventType string, data []byte) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, eventType, data)
+	return err
+}
+
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	var authorID uuid.NullUUID
+	if authorIDStr := r.URL.Query().Get("author_id"); authorIDStr != "" {
+		id, err := uuid.Parse(authorIDStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	// EventSource sends Last-Event-ID when it reconnects. The query
+	// parameter lets a client resume on its first connection.
+	var lastEventID int64
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventIDStr != "" {
+		id, err := strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || id < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+		lastEventID = id
+	}
+
+	// The stream is public, but a signed-in caller's stream is closed if
+	// they are suspended or banned while it is open.
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming unsupported", nil)
+		return
+	}
+
+	cfg.streams.Add(1)
+	defer cfg.streams.Done()
+	// The stream stays open longer than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Subscribe before replaying so nothing published in between is missed.
+	// Live events arrive in stream order, so those already sent during the
+	// replay are skipped.
+	sub := cfg.broker.Subscribe(func(ev broker.Event) bool {
+		if ev.Type == eventAccountRestricted {
+			return viewerID != uuid.Nil && ev.UserID == viewerID
+		}
+		return ev.Public &&
+			slices.Contains(streamedChirpEvents, ev.Type) &&
+			(!authorID.Valid || ev.UserID == authorID.UUID)
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+
+	if lastEventIDStr != "" {
+		if err := cfg.replayStream(w, r, flusher, &lastEventID, authorID); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(streamKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.draining:
+			// The server is shutting down. The client reconnects, to
+			// another instance, and resumes from its last event.
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// resumes from its last event.
+				return
+			}
+			if ev.Type == eventAccountRestricted {
+				return
+			}
+			if ev.ID <= lastEventID {
+				continue
+			}
+			if ev.Type == eventChirpCreated {
+				// The author may have been restricted since the chirp
+				// was published.
+				restricted, err := cfg.isAuthorRestricted(r.Context(), ev.UserID)
+				if err != nil {
+					return
+				}
+				if restricted {
+					continue
+				}
+			}
+			if err := writeStreamEvent(w, ev.ID, ev.Type, ev.Data); err != nil {
+				return
+			}
+			lastEventID = ev.ID
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// replayStream sends the public events after *lastEventID and advances it
+// to the last one sent. A position older than the replay window gets a
+// reset event instead. An error means the stream should be closed, either
+// because it failed or because the rest of the backlog is left to the next
+// connection.
+func (cfg *apiConfig) replayStream(w http.ResponseWriter, r *http.Request, flusher http.Flusher, lastEventID *int64, authorID uuid.NullUUID) error {
+	expired, err := cfg.db.IsStreamPositionExpired(r.Context(), database.IsStreamPositionExpiredParams{
+		AfterSeq:      *lastEventID,
+		WindowSeconds: cfg.streamReplayWindow.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+	if expired {
+		latest, err := cfg.db.GetLatestStreamSeq(r.Context())
+		if err != nil {
+			return err
+		}
+		if err := writeStreamEvent(w, latest, streamEventReset, []byte("{}")); err != nil {
+			return err
+		}
+		*lastEventID = latest
+		return nil
+	}
+
+	for replayed := 0; ; {
+		if replayed >= maxStreamReplay {
+			// Hand the rest of the backlog to the next connection, which
+			// resumes from the last event sent.
+			flusher.Flush()
+			return errStreamReplayLimit
+		}
+		events, err := cfg.db.GetPublicOutboxEventsAfter(r.Context(), database.GetPublicOutboxEventsAfterParams{
+			AfterSeq:      *lastEventID,
+			WindowSeconds: cfg.streamReplayWindow.Seconds(),
+			EventTypes:    streamedChirpEvents,
+			UserID:        authorID,
+			MaxResults:    streamReplayBatchSize,
+		})
+		if err != nil {
+			// The stream has already started, so the client can only be
+			// told to reconnect.
+			return err
+		}
+		for _, event := range events {
+			payload, err := replayPayload(event)
+			if err != nil {
+				return err
+			}
+			if err := writeStreamEvent(w, event.StreamSeq.Int64, event.EventType, payload); err != nil {
+				return err
+			}
+			*lastEventID = event.StreamSeq.Int64
+		}
+		replayed += len(events)
+		if len(events) < streamReplayBatchSize {
+			return nil
+		}
+	}
+}
+
+// replayPayload returns the payload to replay for event. A chirp.created
+// payload gets the chirp's current body, so replaying doesn't bring back
+// text that has since been edited away.
+func replayPayload(event database.GetPublicOutboxEventsAfterRow) ([]byte, error) {
+	if event.EventType != eventChirpCreated || !event.ChirpBody.Valid {
+		return event.Payload, nil
+	}
+	var chirp Chirp
+	if err := json.Unmarshal(event.Payload, &chirp); err != nil {
+		return nil, err
+	}
+	chirp.Body = event.ChirpBody.String
+	chirp.UpdatedAt = event.ChirpUpdatedAt.Time
+	return json.Marshal(chirp)
+}
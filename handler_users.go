@@ -95,7 +95,7 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(r, token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid access token", err)
 		return
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastStatusCode *int32     `json:"last_status_code"`
+	LastError      *string    `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+func webhookSubscriptionFromDB(sub database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        sub.ID,
+		CreatedAt: sub.CreatedAt,
+		UpdatedAt: sub.UpdatedAt,
+		URL:       sub.Url,
+		Events:    sub.Events,
+		Active:    sub.Active,
+	}
+}
+
+func webhookDeliveryFromDB(delivery database.GetWebhookDeliveriesRow) WebhookDelivery {
+	resp := WebhookDelivery{
+		ID:        delivery.ID,
+		CreatedAt: delivery.CreatedAt,
+		UpdatedAt: delivery.UpdatedAt,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+	}
+	if delivery.Status == webhookDeliveryPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return resp
+}
+
+// validateWebhookURL checks that a subscription endpoint is an absolute
+// HTTPS URL whose host resolves to public addresses only. Plain HTTP is only
+// accepted on the dev platform.
+func (cfg *apiConfig) validateWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return errors.New("url must be absolute")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && cfg.platform == "dev") {
+		return errors.New("url must use https")
+	}
+	return checkWebhookHost(ctx, u.Hostname())
+}
+
+// validateWebhookEvents checks that every requested event type is one that
+// is emitted, dropping duplicates.
+func validateWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errors.New("at least one event is required")
+	}
+	valid := make([]string, 0, len(events))
+	for _, event := range events {
+		if !slices.Contains(outgoingEventTypes, event) {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+		if !slices.Contains(valid, event) {
+			valid = append(valid, event)
+		}
+	}
+	return valid, nil
+}
+
+func (cfg *apiConfig) handlerCreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if err := cfg.validateWebhookURL(r.Context(), req.URL); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook URL", err)
+		return
+	}
+
+	events, err := validateWebhookEvents(req.Events)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate signing secret", err)
+		return
+	}
+	secret = "whsec_" + secret
+
+	sub, err := cfg.db.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    req.URL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create webhook subscription", err)
+		return
+	}
+
+	// The signing secret is only ever returned when the subscription is
+	// created.
+	resp := webhookSubscriptionFromDB(sub)
+	resp.Secret = sub.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerGetWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	dbSubs, err := cfg.db.GetWebhookSubscriptionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get webhook subscriptions", err)
+		return
+	}
+
+	subs := make([]WebhookSubscription, 0, len(dbSubs))
+	for _, sub := range dbSubs {
+		subs = append(subs, webhookSubscriptionFromDB(sub))
+	}
+
+	respondWithJSON(w, http.StatusOK, subs)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete webhook subscription", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Webhook subscription not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 500
+	)
+
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	_, err = cfg.db.GetWebhookSubscription(r.Context(), database.GetWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Webhook subscription not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get webhook subscription", err)
+		return
+	}
+
+	dbDeliveries, err := cfg.db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		SubscriptionID: subscriptionID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to get webhook deliveries", err)
+		return
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(dbDeliveries))
+	for _, delivery := range dbDeliveries {
+		deliveries = append(deliveries, webhookDeliveryFromDB(delivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription ID", err)
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, err := cfg.validateAccessToken(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	_, err = cfg.db.GetWebhookSubscription(r.Context(), database.GetWebhookSubscriptionParams{
+		ID:     subscriptionID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Webhook subscription not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Unable to get webhook subscription", err)
+		return
+	}
+
+	retried, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: subscriptionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retry webhook delivery", err)
+		return
+	}
+	if retried == 0 {
+		respondWithError(w, http.StatusNotFound, "Dead-lettered delivery not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,53 +1,113 @@
 package main
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
 )
 
+const maxWebhookBodyBytes = 1 << 20
+
 func (cfg *apiConfig) handlerWebhooks(w http.ResponseWriter, r *http.Request) {
-	type Data struct {
-		UserID uuid.UUID `json:"user_id"`
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Unable to read request body", err)
+		return
 	}
 
-	type webhookRequest struct {
-		Event string `json:"event"`
-		Data  Data   `json:"data"`
+	signed := len(cfg.polkaWebhookSecrets) > 0
+	if !cfg.authenticateWebhook(r, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
 
-	apikey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apikey != cfg.apiKey {
-		w.WriteHeader(http.StatusUnauthorized)
+	params := polkaWebhook{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := webhookRequest{}
-	if err := decoder.Decode(&params); err != nil {
-		w.WriteHeader(http.StatusNoContent)
+	// Signed payloads must carry an event ID so that replays within the
+	// timestamp tolerance window can be detected.
+	if signed && params.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing event ID", nil)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	// Without an event ID there is nothing to deduplicate on: identical
+	// payloads can be separate events, such as a second upgrade after a
+	// downgrade. Each one is stored under an ID of its own.
+	eventID := params.ID
+	if eventID == "" {
+		eventID = "generated:" + uuid.NewString()
+	}
+
+	_, err = cfg.db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		ID:        eventID,
+		EventType: params.Event,
+		Payload:   body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Unable to load webhook event", err)
+			return
+		}
+		// Failed events are retried when Polka redelivers them; anything
+		// else has already been handled and is rejected as a replay.
+		if existing.Status != webhookStatusFailed && existing.Status != webhookStatusPending {
+			cfg.metrics.webhookEvents.WithLabelValues(webhookResultDuplicate).Inc()
+			respondWithError(w, http.StatusConflict, "Event has already been processed", nil)
+			return
+		}
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to store webhook event", err)
 		return
 	}
 
-	err = cfg.db.SetUserPremiumByID(r.Context(), params.Data.UserID)
+	err = cfg.processWebhookEvent(r.Context(), eventID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) || strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, errUserNotFound) {
+			cfg.metrics.webhookEvents.WithLabelValues(webhookResultUserNotFound).Inc()
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		w.WriteHeader(http.StatusNoContent)
+		cfg.metrics.webhookEvents.WithLabelValues(webhookResultFailed).Inc()
+		respondWithError(w, http.StatusInternalServerError, "Unable to process webhook event", err)
 		return
 	}
 
+	cfg.metrics.webhookEvents.WithLabelValues(webhookResultProcessed).Inc()
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// authenticateWebhook checks a Polka request. When signing secrets are
+// configured the body signature is required; otherwise the static API key
+// is compared in constant time.
+func (cfg *apiConfig) authenticateWebhook(r *http.Request, body []byte) bool {
+	if len(cfg.polkaWebhookSecrets) > 0 {
+		err := auth.VerifySignature(
+			body,
+			r.Header.Get("X-Polka-Timestamp"),
+			r.Header.Get("X-Polka-Signature"),
+			cfg.polkaWebhookSecrets,
+			cfg.polkaSignatureTolerance,
+			time.Now(),
+		)
+		return err == nil
+	}
+
+	apikey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(apikey), []byte(cfg.apiKey)) == 1
+}
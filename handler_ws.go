@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/broker"
+)
+
+const (
+	wsChannelTimeline      = "timeline"
+	wsChannelNotifications = "notifications"
+	wsChannelHashtagPrefix = "hashtag:"
+)
+
+const (
+	wsSendQueueSize  = 64
+	wsPingInterval   = 30 * time.Second
+	wsWriteTimeout   = 10 * time.Second
+	wsMaxMessageSize = 4096
+	maxWSChannels    = 20
+)
+
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Token   string `json:"token"`
+}
+
+type wsServerMessage struct {
+	Type      string          `json:"type"`
+	Channel   string          `json:"channel,omitempty"`
+	ID        int64           `json:"id,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+// wsConn is one authenticated WebSocket connection and the channels it has
+// subscribed to.
+type wsConn struct {
+	cfg    *apiConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+
+	// send queues replies to client messages. It is bounded: a client that
+	// doesn't read its replies is disconnected.
+	send chan wsServerMessage
+	// reauth carries the expiry of a refreshed access token.
+	reauth chan time.Time
+
+	mu        sync.Mutex
+	channels  map[string]struct{}
+	followees map[uuid.UUID]struct{}
+	// hidden holds the users muted by or blocked with this user, whose
+	// chirps are left out of hashtag channels.
+	hidden map[uuid.UUID]struct{}
+}
+
+// streamedChirp is the part of a chirp event payload used for routing.
+type streamedChirp struct {
+	Body       string `json:"body"`
+	Visibility string `json:"visibility"`
+}
+
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Browsers can't set headers on a WebSocket handshake, so the token may
+	// also be passed as a query parameter.
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT: ", err)
+		return
+	}
+
+	userID, expiresAt, err := cfg.validateAccessTokenExpiry(r, token)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unable to validate JWT: ", err)
+		return
+	}
+
+	cfg.streams.Add(1)
+	defer cfg.streams.Done()
+	// The connection stays open longer than the server's read and write
+	// timeouts, which would otherwise still apply once it is hijacked.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept has already written an error response.
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	c := &wsConn{
+		cfg:      cfg,
+		conn:     conn,
+		userID:   userID,
+		send:     make(chan wsServerMessage, wsSendQueueSize),
+		reauth:   make(chan time.Time, 1),
+		channels: make(map[string]struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub := cfg.broker.Subscribe(func(ev broker.Event) bool {
+		if ev.Type == eventAccountRestricted {
+			return ev.UserID == c.userID
+		}
+		_, ok := c.channelFor(ev)
+		return ok
+	})
+	defer sub.Close()
+
+	go func() {
+		defer cancel()
+		c.readLoop(ctx)
+	}()
+
+	c.writeLoop(ctx, sub, expiresAt)
+}
+
+// readLoop handles client messages until the connection fails or ctx is
+// cancelled.
+func (c *wsConn) readLoop(ctx context.Context) {
+	for {
+		var msg wsClientMessage
+		if err := wsjson.Read(ctx, c.conn, &msg); err != nil {
+			return
+		}
+
+		reply := c.handleMessage(ctx, msg)
+		select {
+		case c.send <- reply:
+		default:
+			c.conn.Close(websocket.StatusPolicyViolation, "send queue full")
+			return
+		}
+	}
+}
+
+func (c *wsConn) handleMessage(ctx context.Context, msg wsClientMessage) wsServerMessage {
+	switch msg.Type {
+	case "subscribe":
+		if err := c.subscribe(ctx, msg.Channel); err != nil {
+			return wsServerMessage{Type: "error", Channel: msg.Channel, Error: err.Error()}
+		}
+		return wsServerMessage{Type: "subscribed", Channel: msg.Channel}
+	case "unsubscribe":
+		c.mu.Lock()
+		delete(c.channels, msg.Channel)
+		c.mu.Unlock()
+		return wsServerMessage{Type: "unsubscribed", Channel: msg.Channel}
+	case "auth":
+		userID, expiresAt, err := auth.ValidateJWTExpiry(msg.Token, c.cfg.secret)
+		if err != nil || userID != c.userID {
+			return wsServerMessage{Type: "error", Error: "invalid token"}
+		}
+		user, err := c.cfg.db.GetUserByID(ctx, userID)
+		if err != nil {
+			return wsServerMessage{Type: "error", Error: "invalid token"}
+		}
+		if restriction := restrictionFor(user); restriction != nil {
+			return wsServerMessage{Type: "error", Error: restriction.Error()}
+		}
+		select {
+		case <-c.reauth:
+		default:
+		}
+		c.reauth <- expiresAt
+		return wsServerMessage{Type: "authenticated", ExpiresAt: &expiresAt}
+	default:
+		return wsServerMessage{Type: "error", Error: "unknown message type"}
+	}
+}
+
+func (c *wsConn) subscribe(ctx context.Context, channel string) error {
+	switch {
+	case channel == wsChannelTimeline:
+		// The followed accounts are loaded once per subscription.
+		// Resubscribing picks up follows made since.
+		ids, err := c.cfg.db.GetFolloweeIDs(ctx, c.userID)
+		if err != nil {
+			return errors.New("unable to load timeline")
+		}
+		followees := make(map[uuid.UUID]struct{}, len(ids))
+		for _, id := range ids {
+			followees[id] = struct{}{}
+		}
+		c.mu.Lock()
+		c.followees = followees
+		c.mu.Unlock()
+	case channel == wsChannelNotifications:
+	case strings.HasPrefix(channel, wsChannelHashtagPrefix):
+		tag := strings.TrimPrefix(channel, wsChannelHashtagPrefix)
+		if tag == "" || tag != strings.ToLower(tag) || strings.IndexFunc(tag, func(r rune) bool { return !isHashtagRune(r) }) >= 0 {
+			return errors.New("hashtags must be lowercase letters, digits or underscores")
+		}
+		// Like followees, mutes and blocks are reloaded on each subscribe.
+		ids, err := c.cfg.db.GetHiddenUserIDs(ctx, c.userID)
+		if err != nil {
+			return errors.New("unable to load hashtag")
+		}
+		hidden := make(map[uuid.UUID]struct{}, len(ids))
+		for _, id := range ids {
+			hidden[id] = struct{}{}
+		}
+		c.mu.Lock()
+		c.hidden = hidden
+		c.mu.Unlock()
+	default:
+		return errors.New("unknown channel")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.channels[channel]; !ok && len(c.channels) >= maxWSChannels {
+		return errors.New("too many channels")
+	}
+	c.channels[channel] = struct{}{}
+	return nil
+}
+
+// channelFor returns the subscribed channel an event should be sent on, if
+// any. It is called by the broker for every published event.
+func (c *wsConn) channelFor(ev broker.Event) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.channels) == 0 {
+		return "", false
+	}
+
+	if _, ok := c.channels[wsChannelNotifications]; ok {
+		if ev.UserID == c.userID && !ev.Public && (ev.Type == eventNotification || ev.Type == eventUserUpgraded || ev.Type == eventUserDowngraded) {
+			return wsChannelNotifications, true
+		}
+	}
+
+	if ev.Type != eventChirpCreated && ev.Type != eventChirpDeleted {
+		return "", false
+	}
+	var chirp streamedChirp
+	if err := json.Unmarshal(ev.Data, &chirp); err != nil {
+		return "", false
+	}
+
+	if _, ok := c.channels[wsChannelTimeline]; ok {
+		_, followed := c.followees[ev.UserID]
+		if ev.UserID == c.userID ||
+			(followed && (chirp.Visibility == chirpVisibilityPublic || chirp.Visibility == chirpVisibilityFollowers)) {
+			return wsChannelTimeline, true
+		}
+	}
+
+	if _, hidden := c.hidden[ev.UserID]; ev.Type == eventChirpCreated && ev.Public && !hidden {
+		for _, tag := range hashtagsIn(chirp.Body) {
+			if _, ok := c.channels[wsChannelHashtagPrefix+tag]; ok {
+				return wsChannelHashtagPrefix + tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+// writeLoop sends events and replies, pings the client and closes the
+// connection when the access token expires or the user is suspended or
+// banned.
+func (c *wsConn) writeLoop(ctx context.Context, sub *broker.Subscription, expiresAt time.Time) {
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var msg wsServerMessage
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.cfg.draining:
+			c.conn.Close(websocket.StatusGoingAway, "server shutting down")
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				c.conn.Close(websocket.StatusTryAgainLater, "falling behind")
+				return
+			}
+			if ev.Type == eventAccountRestricted {
+				c.conn.Close(websocket.StatusPolicyViolation, "account restricted")
+				return
+			}
+			channel, ok := c.channelFor(ev)
+			if !ok {
+				continue
+			}
+			msg = wsServerMessage{
+				Type:    "event",
+				Channel: channel,
+				ID:      ev.ID,
+				Event:   ev.Type,
+				Data:    ev.Data,
+			}
+		case msg = <-c.send:
+		case <-ping.C:
+			pingCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := c.conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+			continue
+		case expiresAt := <-c.reauth:
+			expiry.Reset(time.Until(expiresAt))
+			continue
+		case <-expiry.C:
+			c.conn.Close(websocket.StatusPolicyViolation, "token expired")
+			return
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+		err := wsjson.Write(writeCtx, c.conn, msg)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func isHashtagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// hashtagsIn returns the lowercased hashtags in body, without the leading #.
+func hashtagsIn(body string) []string {
+	var tags []string
+	for _, field := range strings.FieldsFunc(body, func(r rune) bool { return r != '#' && !isHashtagRune(r) }) {
+		for _, part := range strings.Split(field, "#")[1:] {
+			if part != "" {
+				tags = append(tags, strings.ToLower(part))
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,231 @@
+// Package archive defines the account archive format: a ZIP file holding a
+// JSON profile and JSON Lines files with one record per line.
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Names of the files in an archive.
+const (
+	ProfileFile    = "profile.json"
+	ChirpsFile     = "chirps.jsonl"
+	FollowsFile    = "follows.jsonl"
+	LikesFile      = "likes.jsonl"
+	SessionsFile   = "sessions.jsonl"
+	EventsFile     = "events.jsonl"
+	ModerationFile = "moderation.jsonl"
+)
+
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type Chirp struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	Status     string     `json:"status,omitempty"`
+	Visibility string     `json:"visibility,omitempty"`
+	ReplyToID  *uuid.UUID `json:"reply_to_id,omitempty"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	Revisions  []Revision `json:"revisions,omitempty"`
+	Poll       *Poll      `json:"poll,omitempty"`
+}
+
+// Revision is an earlier body of an edited chirp.
+type Revision struct {
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body"`
+}
+
+type Poll struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Options   []string  `json:"options"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Like struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is a refresh token, without the token itself.
+type Session struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Event is an entry in the user's activity log.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ModerationAction is an action a moderator took against the user.
+type ModerationAction struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Action         string     `json:"action"`
+	Reason         string     `json:"reason"`
+	ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// Writer writes an archive.
+type Writer struct {
+	zw *zip.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// WriteJSON adds a file holding v as indented JSON.
+func (w *Writer) WriteJSON(name string, v any) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// WriteJSONLines adds a file holding records as JSON Lines.
+func WriteJSONLines[T any](w *Writer, name string, records []T) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+// maxLineSize caps the length of one line in a JSON Lines file.
+const maxLineSize = 1 << 20
+
+// ErrNoChirps is returned by OpenChirps for a ZIP archive without a chirps
+// file.
+var ErrNoChirps = errors.New("archive has no " + ChirpsFile)
+
+// OpenChirps returns a reader for the chirps in data, which is either a ZIP
+// archive or the contents of a chirps file.
+func OpenChirps(data []byte) (*ChirpReader, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return NewChirpReader(bytes.NewReader(data)), nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	f, err := zr.Open(ChirpsFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoChirps
+		}
+		return nil, err
+	}
+	return NewChirpReader(f), nil
+}
+
+// ErrInvalidUTF8 is wrapped in the *LineError for a line that isn't valid
+// UTF-8.
+var ErrInvalidUTF8 = errors.New("line is not valid UTF-8")
+
+// LineError reports a line that doesn't hold a valid record.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ChirpReader reads chirps from a JSON Lines file.
+type ChirpReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewChirpReader(r io.Reader) *ChirpReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ChirpReader{scanner: scanner}
+}
+
+// Next returns the next chirp, skipping blank lines. It returns io.EOF after
+// the last one. A line that isn't a valid chirp gives a *LineError, and
+// reading can carry on with the next line. Any other error is fatal.
+func (r *ChirpReader) Next() (Chirp, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		// encoding/json would replace invalid UTF-8 with U+FFFD rather
+		// than fail.
+		if !utf8.Valid(line) {
+			return Chirp{}, &LineError{Line: r.line, Err: ErrInvalidUTF8}
+		}
+
+		var chirp Chirp
+		if err := json.Unmarshal(line, &chirp); err != nil {
+			return Chirp{}, &LineError{Line: r.line, Err: err}
+		}
+		return chirp, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Chirp{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Chirp{}, io.EOF
+}
+
+// Line returns the number of the last line read, starting at 1.
+func (r *ChirpReader) Line() int {
+	return r.line
+}
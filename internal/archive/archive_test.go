@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteJSON(ProfileFile, Profile{Email: "user@example.com"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	chirps := []Chirp{
+		{ID: uuid.New(), CreatedAt: created, Body: "first"},
+		{ID: uuid.New(), CreatedAt: created, Body: "second"},
+	}
+	if err := WriteJSONLines(w, ChirpsFile, chirps); err != nil {
+		t.Fatalf("WriteJSONLines() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	if !bytes.Contains([]byte(files[ProfileFile]), []byte(`"email": "user@example.com"`)) {
+		t.Errorf("%s = %q, want indented profile", ProfileFile, files[ProfileFile])
+	}
+	lines := bytes.Split(bytes.TrimSpace([]byte(files[ChirpsFile])), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("%s has %d lines, want 2", ChirpsFile, len(lines))
+	}
+	if !bytes.Contains(lines[1], []byte(`"body":"second"`)) {
+		t.Errorf("second line = %s, want the second chirp", lines[1])
+	}
+	if bytes.Contains(lines[0], []byte("revisions")) {
+		t.Errorf("first line = %s, want empty fields omitted", lines[0])
+	}
+}
+
+func TestOpenChirps(t *testing.T) {
+	jsonl := "{\"body\":\"first\",\"created_at\":\"2024-01-02T03:04:05Z\"}\n" +
+		"\n" +
+		"not json\n" +
+		"{\"body\":\"second\",\"created_at\":\"2024-01-03T03:04:05Z\"}\n"
+
+	var zipped bytes.Buffer
+	w := zip.NewWriter(&zipped)
+	f, _ := w.Create(ChirpsFile)
+	f.Write([]byte(jsonl))
+	w.Close()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"json lines", []byte(jsonl)},
+		{"zip", zipped.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := OpenChirps(tt.data)
+			if err != nil {
+				t.Fatalf("OpenChirps() error = %v", err)
+			}
+
+			chirp, err := r.Next()
+			if err != nil || chirp.Body != "first" || r.Line() != 1 {
+				t.Fatalf("Next() = %q, %v at line %d, want first at line 1", chirp.Body, err, r.Line())
+			}
+
+			_, err = r.Next()
+			var lineErr *LineError
+			if !errors.As(err, &lineErr) || lineErr.Line != 3 {
+				t.Fatalf("Next() error = %v, want a LineError for line 3", err)
+			}
+
+			chirp, err = r.Next()
+			if err != nil || chirp.Body != "second" || r.Line() != 4 {
+				t.Fatalf("Next() = %q, %v at line %d, want second at line 4", chirp.Body, err, r.Line())
+			}
+			want := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+			if !chirp.CreatedAt.Equal(want) {
+				t.Errorf("CreatedAt = %v, want %v", chirp.CreatedAt, want)
+			}
+
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("Next() error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestChirpReaderInvalidUTF8(t *testing.T) {
+	r := NewChirpReader(strings.NewReader("{\"body\":\"bad \xff byte\"}\n"))
+	_, err := r.Next()
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Next() error = %v, want a LineError wrapping ErrInvalidUTF8", err)
+	}
+}
+
+func TestOpenChirpsWithoutChirpsFile(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteJSON(ProfileFile, Profile{})
+	w.Close()
+
+	if _, err := OpenChirps(buf.Bytes()); !errors.Is(err, ErrNoChirps) {
+		t.Errorf("OpenChirps() error = %v, want ErrNoChirps", err)
+	}
+}
@@ -42,25 +42,32 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	id, _, err := ValidateJWTExpiry(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTExpiry validates a token like ValidateJWT and also returns when
+// it expires, for long-lived connections that must end with the token.
+func ValidateJWTExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
 	claims := &jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithExpirationRequired())
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 
 	tokenClaims, ok := token.Claims.(*jwt.RegisteredClaims)
 	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid token claims")
+		return uuid.Nil, time.Time{}, fmt.Errorf("invalid token claims")
 	}
 
 	id, err := uuid.Parse(tokenClaims.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 
-	return id, nil
+	return id, tokenClaims.ExpiresAt.Time, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -111,6 +111,23 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+func TestValidateJWTExpiry(t *testing.T) {
+	userID := uuid.New()
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, _ := MakeJWT(userID, "secret", time.Hour)
+
+	gotUserID, expiresAt, err := ValidateJWTExpiry(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateJWTExpiry() error = %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("ValidateJWTExpiry() gotUserID = %v, want %v", gotUserID, userID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(before.Add(time.Minute)) {
+		t.Errorf("ValidateJWTExpiry() expiresAt = %v, want about %v", expiresAt, before)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -174,3 +191,176 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	timestamp := "1700000000"
+	current := SignPayload("current_secret", timestamp, body)
+	previous := SignPayload("previous_secret", timestamp, body)
+	secrets := []string{"current_secret", "previous_secret"}
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+		now       time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "Valid signature",
+			body:      body,
+			timestamp: timestamp,
+			signature: current,
+			now:       now,
+			wantErr:   false,
+		},
+		{
+			name:      "Signed with previous secret",
+			body:      body,
+			timestamp: timestamp,
+			signature: previous,
+			now:       now,
+			wantErr:   false,
+		},
+		{
+			name:      "Tampered body",
+			body:      []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			timestamp: timestamp,
+			signature: current,
+			now:       now,
+			wantErr:   true,
+		},
+		{
+			name:      "Unknown secret",
+			body:      body,
+			timestamp: timestamp,
+			signature: SignPayload("other_secret", timestamp, body),
+			now:       now,
+			wantErr:   true,
+		},
+		{
+			name:      "Timestamp outside tolerance",
+			body:      body,
+			timestamp: timestamp,
+			signature: current,
+			now:       now.Add(10 * time.Minute),
+			wantErr:   true,
+		},
+		{
+			name:      "Missing timestamp",
+			body:      body,
+			timestamp: "",
+			signature: current,
+			now:       now,
+			wantErr:   true,
+		},
+		{
+			name:      "Malformed signature",
+			body:      body,
+			timestamp: timestamp,
+			signature: "not-a-signature",
+			now:       now,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.body, tt.timestamp, tt.signature, secrets, 5*time.Minute, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	path := "/api/exports/abc/download"
+	expires := "1700003600"
+	signature := SignURL("secret", path, expires)
+
+	tests := []struct {
+		name      string
+		path      string
+		expires   string
+		signature string
+		now       time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "Valid link",
+			path:      path,
+			expires:   expires,
+			signature: signature,
+			now:       now,
+			wantErr:   false,
+		},
+		{
+			name:      "Expired link",
+			path:      path,
+			expires:   expires,
+			signature: signature,
+			now:       now.Add(2 * time.Hour),
+			wantErr:   true,
+		},
+		{
+			name:      "Different path",
+			path:      "/api/exports/def/download",
+			expires:   expires,
+			signature: signature,
+			now:       now,
+			wantErr:   true,
+		},
+		{
+			name:      "Extended expiry",
+			path:      path,
+			expires:   "1800000000",
+			signature: signature,
+			now:       now,
+			wantErr:   true,
+		},
+		{
+			name:      "Invalid expiry",
+			path:      path,
+			expires:   "soon",
+			signature: signature,
+			now:       now,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignedURL("secret", tt.path, tt.expires, tt.signature, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySignedURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	key, err := DeriveKey("secret", "export-url")
+	if err != nil {
+		t.Fatalf("DeriveKey() error = %v", err)
+	}
+	again, _ := DeriveKey("secret", "export-url")
+	if key != again {
+		t.Errorf("DeriveKey() = %q then %q, want the same key", key, again)
+	}
+
+	other, _ := DeriveKey("secret", "other")
+	if key == other || key == "secret" {
+		t.Errorf("DeriveKey() = %q, want a key distinct from other labels and the secret", key)
+	}
+
+	path := "/api/exports/abc/download"
+	expires := "1700003600"
+	err = VerifySignedURL(key, path, expires, SignURL("secret", path, expires), time.Unix(1700000000, 0))
+	if err == nil {
+		t.Error("VerifySignedURL() accepted a link signed with the underlying secret")
+	}
+}
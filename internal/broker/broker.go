@@ -0,0 +1,113 @@
+package broker
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is a committed outbox event fanned out to live subscribers.
+type Event struct {
+	// ID is the event's position in the stream. Events are published in
+	// ID order.
+	ID     int64
+	Type   string
+	UserID uuid.UUID
+	Public bool
+	Data   json.RawMessage
+}
+
+// Broker fans events out to in-process subscribers. Publishing never
+// blocks: a subscriber whose buffer is full is dropped and its channel
+// closed, so one slow client can't hold up the rest. It is safe for
+// concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+// Subscription receives the events that match its filter.
+type Subscription struct {
+	broker *Broker
+	filter func(Event) bool
+	events chan Event
+}
+
+// New returns a broker whose subscribers buffer up to buffer events.
+func New(buffer int) *Broker {
+	return &Broker{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe registers a subscriber for events that match filter. A nil
+// filter matches every event.
+func (b *Broker) Subscribe(filter func(Event) bool) *Subscription {
+	sub := &Subscription{
+		broker: b,
+		filter: filter,
+		events: make(chan Event, b.buffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers ev to every matching subscriber.
+func (b *Broker) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(ev) {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			delete(b.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Close drops every subscriber and rejects new ones.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Events returns the subscriber's channel. It is closed when the
+// subscription is closed, the subscriber falls too far behind, or the broker
+// shuts down.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishFiltersEvents(t *testing.T) {
+	b := New(4)
+	author := uuid.New()
+
+	all := b.Subscribe(nil)
+	defer all.Close()
+	byAuthor := b.Subscribe(func(ev Event) bool { return ev.UserID == author })
+	defer byAuthor.Close()
+
+	b.Publish(Event{ID: 1, UserID: uuid.New()})
+	b.Publish(Event{ID: 2, UserID: author})
+
+	if got := len(all.Events()); got != 2 {
+		t.Errorf("unfiltered subscriber got %d events, want 2", got)
+	}
+	if got := len(byAuthor.Events()); got != 1 {
+		t.Fatalf("filtered subscriber got %d events, want 1", got)
+	}
+	if ev := <-byAuthor.Events(); ev.ID != 2 {
+		t.Errorf("filtered subscriber got event %d, want 2", ev.ID)
+	}
+}
+
+func TestPublishDropsSlowSubscribers(t *testing.T) {
+	b := New(1)
+	slow := b.Subscribe(nil)
+
+	b.Publish(Event{ID: 1})
+	b.Publish(Event{ID: 2})
+
+	if ev, ok := <-slow.Events(); !ok || ev.ID != 1 {
+		t.Fatalf("first receive = %v, %v; want event 1", ev.ID, ok)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Error("slow subscriber's channel is still open")
+	}
+
+	// Closing an already dropped subscription must not panic.
+	slow.Close()
+}
+
+func TestClose(t *testing.T) {
+	b := New(1)
+	sub := b.Subscribe(nil)
+
+	b.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Error("subscription still open after broker closed")
+	}
+
+	late := b.Subscribe(nil)
+	if _, ok := <-late.Events(); ok {
+		t.Error("subscription after close is open")
+	}
+	sub.Close()
+}
@@ -0,0 +1,153 @@
+// Package chirptext cleans up user-written text and measures it the way
+// chirp length limits are counted: in user-perceived characters (grapheme
+// clusters) rather than bytes, with every URL counting as a fixed length.
+package chirptext
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLLength is how many characters a URL counts as, however long it is.
+const URLLength = 23
+
+// maxCombiningMarks is how many combining marks a single character may
+// carry. Real scripts need a few; "Zalgo" text stacks dozens.
+const maxCombiningMarks = 4
+
+var ErrInvalidUTF8 = errors.New("text is not valid UTF-8")
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// Normalize validates text as UTF-8 and returns it in NFC form with control
+// characters, invisible formatting characters and excess combining marks
+// removed. Newlines and tabs are kept, as are zero-width joiners between
+// visible characters, which emoji sequences and some scripts rely on, and
+// tag characters that spell out a subdivision flag.
+func Normalize(text string) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", ErrInvalidUTF8
+	}
+
+	text = norm.NFC.String(text)
+
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	// tags is how many of the following runes are part of the flag tag
+	// sequence being copied.
+	tags := 0
+	for i, r := range runes {
+		switch {
+		case r == '\n' || r == '\t':
+		case r == '\u200c' || r == '\u200d':
+			// Zero-width (non-)joiners only mean something between two
+			// visible characters.
+			if i == 0 || i == len(runes)-1 || !isVisible(runes[i-1]) || !isVisible(runes[i+1]) {
+				continue
+			}
+		case r == blackFlag:
+			tags = tagSequenceLength(runes[i+1:])
+		case isEmojiTag(r):
+			// Tags outside a flag sequence can spell out hidden text.
+			if tags == 0 {
+				continue
+			}
+			tags--
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return limitCombiningMarks(b.String()), nil
+}
+
+func isVisible(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsControl(r) && !unicode.Is(unicode.Cf, r)
+}
+
+// limitCombiningMarks drops combining marks beyond maxCombiningMarks from
+// each grapheme cluster.
+func limitCombiningMarks(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	state := -1
+	for text != "" {
+		var cluster string
+		cluster, text, _, state = uniseg.StepString(text, state)
+		marks := 0
+		for _, r := range cluster {
+			if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) {
+				marks++
+				if marks > maxCombiningMarks && !isEmojiModifier(r) {
+					continue
+				}
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isEmojiModifier reports whether r is a variation selector, which emoji
+// sequences legitimately repeat.
+func isEmojiModifier(r rune) bool {
+	return r >= '\ufe00' && r <= '\ufe0f'
+}
+
+// blackFlag starts the tag sequences of subdivision flags such as
+// England's, and cancelTag ends them.
+const (
+	blackFlag = '\U0001F3F4'
+	cancelTag = '\U000E007F'
+)
+
+// isEmojiTag reports whether r is one of the invisible tag characters that
+// spell out subdivision flags.
+func isEmojiTag(r rune) bool {
+	return r >= 0xe0020 && r <= cancelTag
+}
+
+// tagSequenceLength returns how many runes at the start of runes form the
+// tag sequence of a flag: one or more tag characters followed by
+// cancelTag. It returns 0 if they don't.
+func tagSequenceLength(runes []rune) int {
+	for i, r := range runes {
+		switch {
+		case r == cancelTag:
+			if i == 0 {
+				return 0
+			}
+			return i + 1
+		case !isEmojiTag(r):
+			return 0
+		}
+	}
+	return 0
+}
+
+// Length returns the length of text in grapheme clusters, counting each URL
+// as URLLength characters.
+func Length(text string) int {
+	length := 0
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		end := loc[0] + len(trimURL(text[loc[0]:loc[1]]))
+		length += uniseg.GraphemeClusterCount(text[last:loc[0]]) + URLLength
+		last = end
+	}
+	return length + uniseg.GraphemeClusterCount(text[last:])
+}
+
+// trimURL drops trailing punctuation that more likely ends the sentence
+// than the URL.
+func trimURL(url string) string {
+	return strings.TrimRight(url, ".,;:!?)]}'")
+}
@@ -0,0 +1,72 @@
+package chirptext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"accented", "caf\u00e9", 4},
+		{"decomposed accent", "cafe\u0301", 4},
+		{"emoji", strings.Repeat("\U0001f600", 50), 50},
+		{"family emoji", "\U0001f468\u200d\U0001f469\u200d\U0001f467", 1},
+		{"flag", "\U0001f1fa\U0001f1f8", 1},
+		{"skin tone", "\U0001f44d\U0001f3fd", 1},
+		{"url", "see https://example.com/a/very/long/path/that/goes/on/and/on", 4 + URLLength},
+		{"url with trailing period", "go to http://x.io.", 6 + URLLength + 1},
+		{"two urls", "https://a.example https://b.example", 2*URLLength + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.text); got != tt.want {
+				t.Errorf("Length(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"nfc", "cafe\u0301", "caf\u00e9"},
+		{"newlines kept", "line one\nline two\ttabbed", "line one\nline two\ttabbed"},
+		{"control characters", "bell\a and null\x00", "bell and null"},
+		{"zero-width space", "ker\u200bfuffle", "kerfuffle"},
+		{"byte order mark", "\ufeffhello", "hello"},
+		{"bidi override", "abc\u202edef", "abcdef"},
+		{"joiner in emoji kept", "\U0001f468\u200d\U0001f469", "\U0001f468\u200d\U0001f469"},
+		{"stray joiners", "\u200dhi \u200d\u200d there\u200d", "hi  there"},
+		{"england flag", "\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f", "\U0001f3f4\U000e0067\U000e0062\U000e0065\U000e006e\U000e0067\U000e007f"},
+		{"bare tag run", "hi\U000e0062\U000e0061\U000e0064 there\U000e007f", "hi there"},
+		{"unterminated flag tags", "\U0001f3f4\U000e0067\U000e0062 ok", "\U0001f3f4 ok"},
+		{"tags after flag sequence", "\U0001f3f4\U000e0067\U000e007f\U000e0062", "\U0001f3f4\U000e0067\U000e007f"},
+		{"zalgo", "a" + strings.Repeat("\u0300\u0301\u0302", 10), "\u00e0\u0301\u0302\u0300\u0301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.text)
+			if err != nil {
+				t.Fatalf("Normalize(%q) error = %v", tt.text, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvalidUTF8(t *testing.T) {
+	if _, err := Normalize("bad \xff byte"); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Normalize() error = %v, want ErrInvalidUTF8", err)
+	}
+}
@@ -0,0 +1,338 @@
+// Package contentfilter matches text against a set of banned terms and
+// decides, per term, whether to mask it, reject the text or flag it for
+// review.
+//
+// Text is split into words on Unicode word boundaries. Each word is compared
+// in a normalized form that ignores case, diacritics and common leetspeak
+// substitutions, and letters may be repeated more times than in the term, so
+// "Kerfuffle!", "kérfuffle" and "k3rfuuuffle" all match the term
+// "kerfuffle". Letters can't be repeated fewer times: "kerfufle" doesn't
+// match.
+package contentfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the matched word with asterisks.
+	ActionMask Action = "mask"
+	// ActionFlag leaves the text alone but marks it for moderator review.
+	ActionFlag Action = "flag"
+	// ActionReject refuses the text outright.
+	ActionReject Action = "reject"
+)
+
+// Mask is what masked words are replaced with.
+const Mask = "****"
+
+// severity orders actions so the strictest one wins when a term is listed
+// more than once.
+var severity = map[Action]int{
+	ActionMask:   1,
+	ActionFlag:   2,
+	ActionReject: 3,
+}
+
+// Valid reports whether a is a known action.
+func (a Action) Valid() bool {
+	_, ok := severity[a]
+	return ok
+}
+
+// Rule is a single banned term and what to do when it's found.
+type Rule struct {
+	Term   string `json:"term"`
+	Action Action `json:"action"`
+}
+
+// Match is a word that matched a rule.
+type Match struct {
+	Word   string
+	Term   string
+	Action Action
+}
+
+// Result is the outcome of running text through a Filter.
+type Result struct {
+	// Text is the input with masked words replaced.
+	Text    string
+	Matches []Match
+}
+
+// Rejected reports whether any matched rule rejects the text.
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+// Flagged reports whether any matched rule flags the text for review.
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, m := range r.Matches {
+		if m.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Terms returns the distinct terms matched with the given action.
+func (r Result) Terms(action Action) []string {
+	var terms []string
+	seen := make(map[string]struct{})
+	for _, m := range r.Matches {
+		if _, ok := seen[m.Term]; ok || m.Action != action {
+			continue
+		}
+		seen[m.Term] = struct{}{}
+		terms = append(terms, m.Term)
+	}
+	return terms
+}
+
+// Filter is an immutable, compiled set of rules. It is safe for concurrent
+// use; a nil *Filter matches nothing.
+type Filter struct {
+	// rules indexes the rules by their term's letters with repeats
+	// collapsed, the part a word must spell exactly to match.
+	rules map[string][]compiledRule
+	terms int
+}
+
+type compiledRule struct {
+	Rule
+	runs runs
+}
+
+// New compiles rules into a Filter. Each term must be a single word.
+func New(rules []Rule) (*Filter, error) {
+	byTerm := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		if !rule.Action.Valid() {
+			return nil, fmt.Errorf("rule %q: unknown action %q", rule.Term, rule.Action)
+		}
+		words := splitWords(rule.Term)
+		if len(words) != 1 || words[0].start != 0 || words[0].end != len(rule.Term) {
+			return nil, fmt.Errorf("rule %q: term must be a single word", rule.Term)
+		}
+		key := Normalize(rule.Term)
+		if existing, ok := byTerm[key]; ok && severity[existing.Action] >= severity[rule.Action] {
+			continue
+		}
+		byTerm[key] = rule
+	}
+
+	f := &Filter{rules: make(map[string][]compiledRule, len(byTerm)), terms: len(byTerm)}
+	for key, rule := range byTerm {
+		r := runsOf(key)
+		f.rules[r.letters] = append(f.rules[r.letters], compiledRule{Rule: rule, runs: r})
+	}
+	return f, nil
+}
+
+// LoadFile reads rules from a JSON file containing an array of rules.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Len returns the number of distinct terms in the filter.
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return f.terms
+}
+
+// Apply matches every word in text against the filter's rules.
+func (f *Filter) Apply(text string) Result {
+	result := Result{Text: text}
+	if f.Len() == 0 {
+		return result
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, w := range splitWords(text) {
+		rule, start, end, ok := f.match(text[w.start:w.end])
+		if !ok {
+			continue
+		}
+		start += w.start
+		end += w.start
+		result.Matches = append(result.Matches, Match{
+			Word:   text[start:end],
+			Term:   rule.Term,
+			Action: rule.Action,
+		})
+		if rule.Action == ActionMask {
+			b.WriteString(text[last:start])
+			b.WriteString(Mask)
+			last = end
+		}
+	}
+	if last > 0 {
+		b.WriteString(text[last:])
+		result.Text = b.String()
+	}
+	return result
+}
+
+// match looks word up as a whole, then with any leading or trailing
+// leetspeak symbols trimmed, so "fornax!" is caught but "$hit" still is too.
+// It returns the byte range within word that matched.
+func (f *Filter) match(word string) (Rule, int, int, bool) {
+	if rule, ok := f.lookup(word); ok {
+		return rule, 0, len(word), true
+	}
+	trimmed := strings.TrimFunc(word, isSymbol)
+	if trimmed == "" || trimmed == word {
+		return Rule{}, 0, 0, false
+	}
+	if rule, ok := f.lookup(trimmed); ok {
+		start := strings.Index(word, trimmed)
+		return rule, start, start + len(trimmed), true
+	}
+	return Rule{}, 0, 0, false
+}
+
+// lookup returns the strictest rule whose term word spells, allowing extra
+// repeats of each letter.
+func (f *Filter) lookup(word string) (Rule, bool) {
+	r := runsOf(Normalize(word))
+	var found Rule
+	ok := false
+	for _, rule := range f.rules[r.letters] {
+		if r.covers(rule.runs) && (!ok || severity[rule.Action] > severity[found.Action]) {
+			found, ok = rule.Rule, true
+		}
+	}
+	return found, ok
+}
+
+// leet maps common character substitutions back to the letter they stand
+// for.
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+	'+': 't',
+}
+
+// Normalize reduces a word to the form rules are matched in: lowercased,
+// with diacritics removed and leetspeak substitutions undone.
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if sub, ok := leet[r]; ok {
+			r = sub
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runs is a normalized word split into runs of the same letter: "kerfuffle"
+// has the letters "kerfufle", with the second f repeated twice.
+type runs struct {
+	letters string
+	counts  []int
+}
+
+func runsOf(normalized string) runs {
+	var letters strings.Builder
+	var counts []int
+	var prev rune
+	for _, r := range normalized {
+		if len(counts) > 0 && r == prev {
+			counts[len(counts)-1]++
+			continue
+		}
+		letters.WriteRune(r)
+		counts = append(counts, 1)
+		prev = r
+	}
+	return runs{letters: letters.String(), counts: counts}
+}
+
+// covers reports whether r spells term with every letter repeated at least
+// as often, so "kerfuuuffle" covers "kerfuffle" but "as" doesn't cover
+// "ass".
+func (r runs) covers(term runs) bool {
+	if r.letters != term.letters {
+		return false
+	}
+	for i, n := range term.counts {
+		if r.counts[i] < n {
+			return false
+		}
+	}
+	return true
+}
+
+type span struct {
+	start, end int
+}
+
+// splitWords returns the byte ranges of the words in text. A word is a run
+// of letters, digits, combining marks and leetspeak symbols.
+func splitWords(text string) []span {
+	var words []span
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, span{start, len(text)})
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || isSymbol(r)
+}
+
+// isSymbol reports whether r is a non-alphanumeric leetspeak substitute.
+func isSymbol(r rune) bool {
+	_, ok := leet[r]
+	return ok && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
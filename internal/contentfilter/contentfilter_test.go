@@ -0,0 +1,134 @@
+package contentfilter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestApplyMask(t *testing.T) {
+	f, err := New([]Rule{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "what a kerfuffle", "what a ****"},
+		{"case", "Kerfuffle it is", "**** it is"},
+		{"punctuation", "kerfuffle! Kerfuffle, kerfuffle.", "****! ****, ****."},
+		{"leetspeak", "k3rfuffl3 and $harbert", "**** and ****"},
+		{"repeated letters", "kerfuuuuffle", "****"},
+		{"diacritics", "kérfuffle", "****"},
+		{"possessive", "sharbert's", "****'s"},
+		{"newlines", "first\nkerfuffle\tsecond", "first\n****\tsecond"},
+		{"inside a longer word", "kerfufflement", "kerfufflement"},
+		{"clean", "nothing to see here", "nothing to see here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Apply(tt.text)
+			if got.Text != tt.want {
+				t.Errorf("Apply(%q).Text = %q, want %q", tt.text, got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRepeatedLetters(t *testing.T) {
+	f, err := New([]Rule{
+		{Term: "ass", Action: ActionMask},
+		{Term: "boob", Action: ActionMask},
+		{Term: "sass", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"fewer repeats", "as Bob said, sas", "as Bob said, sas"},
+		{"same repeats", "ass boob sass", "**** **** ****"},
+		{"more repeats", "asssss booooob saaasss", "**** **** ****"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Apply(tt.text); got.Text != tt.want {
+				t.Errorf("Apply(%q).Text = %q, want %q", tt.text, got.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyActions(t *testing.T) {
+	f, err := New([]Rule{
+		{Term: "fornax", Action: ActionReject},
+		{Term: "sharbert", Action: ActionFlag},
+		{Term: "kerfuffle", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got := f.Apply("sharbert and kerfuffle")
+	if got.Rejected() {
+		t.Error("Rejected() = true, want false")
+	}
+	if !got.Flagged() {
+		t.Error("Flagged() = false, want true")
+	}
+	if got.Text != "sharbert and ****" {
+		t.Errorf("Text = %q, want flagged words left alone", got.Text)
+	}
+	if terms := got.Terms(ActionFlag); !slices.Equal(terms, []string{"sharbert"}) {
+		t.Errorf("Terms(flag) = %v, want [sharbert]", terms)
+	}
+
+	if !f.Apply("F0RNAX!").Rejected() {
+		t.Error("Rejected() = false for a rejected term")
+	}
+}
+
+func TestNewStrictestActionWins(t *testing.T) {
+	f, err := New([]Rule{
+		{Term: "fornax", Action: ActionReject},
+		{Term: "Fornax", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if f.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", f.Len())
+	}
+	if !f.Apply("fornax").Rejected() {
+		t.Error("mask rule overrode reject rule")
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	tests := []Rule{
+		{Term: "two words", Action: ActionMask},
+		{Term: "", Action: ActionMask},
+		{Term: "kerfuffle", Action: "delete"},
+	}
+	for _, rule := range tests {
+		if _, err := New([]Rule{rule}); err == nil {
+			t.Errorf("New(%+v) error = nil, want error", rule)
+		}
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if got := f.Apply("kerfuffle"); got.Text != "kerfuffle" || len(got.Matches) != 0 {
+		t.Errorf("nil Filter Apply() = %+v, want no matches", got)
+	}
+}
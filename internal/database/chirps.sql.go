@@ -7,29 +7,202 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const anonymizeRepliedChirps = `-- name: AnonymizeRepliedChirps :many
+UPDATE chirps
+SET user_id = $1,
+    body = '',
+    updated_at = NOW()
+WHERE chirps.user_id = $2
+  AND (
+    EXISTS (
+      SELECT 1 FROM chirps AS replies
+      WHERE replies.reply_to_id = chirps.id AND replies.user_id <> $2
+    )
+    OR (
+      chirps.status = 'published'
+      AND EXISTS (
+        SELECT 1 FROM chirps AS parent
+        WHERE parent.id = chirps.reply_to_id AND parent.user_id <> $2
+      )
+    )
+  )
+RETURNING id
+`
+
+type AnonymizeRepliedChirpsParams struct {
+	DeletedUserID uuid.UUID
+	UserID        uuid.UUID
+}
+
+func (q *Queries) AnonymizeRepliedChirps(ctx context.Context, arg AnonymizeRepliedChirpsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, anonymizeRepliedChirps, arg.DeletedUserID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimDueChirps = `-- name: ClaimDueChirps :many
+-- Chirps by restricted authors wait until the restriction is lifted.
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
+ORDER BY publish_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) ClaimDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.ReplyToID,
+			&i.ModeratedAt,
+			&i.ImportedFromID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, reply_to_id)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4
   )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	Visibility string
+	ReplyToID  uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.Visibility, arg.ReplyToID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const createChirpRevision = `-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions (id, created_at, chirp_id, body)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2
+  )
+`
+
+type CreateChirpRevisionParams struct {
+	ChirpID uuid.UUID
+	Body    string
+}
+
+func (q *Queries) CreateChirpRevision(ctx context.Context, arg CreateChirpRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createChirpRevision, arg.ChirpID, arg.Body)
+	return err
+}
+
+const createDraft = `-- name: CreateDraft :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, visibility)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+  )
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type CreateDraftParams struct {
+	Body       string
+	UserID     uuid.UUID
+	Status     string
+	PublishAt  sql.NullTime
+	Visibility string
+}
+
+func (q *Queries) CreateDraft(ctx context.Context, arg CreateDraftParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createDraft, arg.Body, arg.UserID, arg.Status, arg.PublishAt, arg.Visibility)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,13 +210,22 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1 AND user_id = $2
+UPDATE chirps
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 `
 
 type DeleteChirpParams struct {
@@ -56,13 +238,68 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 	return err
 }
 
+const deleteChirpRevisions = `-- name: DeleteChirpRevisions :exec
+DELETE FROM chirp_revisions
+WHERE chirp_id = $1
+`
+
+func (q *Queries) DeleteChirpRevisions(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpRevisions, chirpID)
+	return err
+}
+
+const deleteDraft = `-- name: DeleteDraft :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+`
+
+type DeleteDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteDraft(ctx context.Context, arg DeleteDraftParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDraft, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
+WHERE deleted_at IS NULL AND status = 'published'
+  AND (
+    visibility = 'public'
+    OR user_id = $1
+    OR (visibility = 'followers' AND EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = $1 AND followee_id = chirps.user_id
+    ))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $1 AND muted_id = chirps.user_id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = chirps.user_id)
+       OR (blocker_id = chirps.user_id AND blocked_id = $1)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +313,13 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.ReplyToID,
+			&i.ModeratedAt,
+			&i.ImportedFromID,
 		); err != nil {
 			return nil, err
 		}
@@ -91,7 +335,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
 WHERE id = $1
 `
 
@@ -104,18 +348,139 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
 	)
 	return i, err
 }
 
+const getChirpRevisionsByUser = `-- name: GetChirpRevisionsByUser :many
+SELECT chirp_revisions.chirp_id, chirp_revisions.created_at, chirp_revisions.body
+FROM chirp_revisions
+JOIN chirps ON chirps.id = chirp_revisions.chirp_id
+WHERE chirps.user_id = $1
+ORDER BY chirp_revisions.created_at ASC
+`
+
+type GetChirpRevisionsByUserRow struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Body      string
+}
+
+func (q *Queries) GetChirpRevisionsByUser(ctx context.Context, userID uuid.UUID) ([]GetChirpRevisionsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpRevisionsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpRevisionsByUserRow
+	for rows.Next() {
+		var i GetChirpRevisionsByUserRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpsByUser = `-- name: GetChirpsByUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND status = 'published'
+  AND (
+    visibility IN ('public', 'unlisted')
+    OR user_id = $2
+    OR (visibility = 'followers' AND EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = $2 AND followee_id = chirps.user_id
+    ))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $2 AND muted_id = chirps.user_id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = $2 AND blocked_id = chirps.user_id)
+       OR (blocker_id = chirps.user_id AND blocked_id = $2)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
+ORDER BY created_at ASC
+`
+
+type GetChirpsByUserParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByUser(ctx context.Context, arg GetChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUser, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.ReplyToID,
+			&i.ModeratedAt,
+			&i.ImportedFromID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsForExport = `-- name: GetChirpsForExport :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUser, userID)
+func (q *Queries) GetChirpsForExport(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsForExport, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -129,6 +494,13 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.ReplyToID,
+			&i.ModeratedAt,
+			&i.ImportedFromID,
 		); err != nil {
 			return nil, err
 		}
@@ -142,3 +514,370 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 	}
 	return items, nil
 }
+
+const getDraft = `-- name: GetDraft :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+`
+
+type GetDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetDraft(ctx context.Context, arg GetDraftParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDraft, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const getDraftsByUser = `-- name: GetDraftsByUser :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled')
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetDraftsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDraftsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.ReplyToID,
+			&i.ModeratedAt,
+			&i.ImportedFromID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasImportedChirp = `-- name: HasImportedChirp :one
+SELECT EXISTS (
+  SELECT 1 FROM chirps
+  WHERE user_id = $1
+    AND (id = $2 OR imported_from_id = $2)
+)
+`
+
+type HasImportedChirpParams struct {
+	UserID     uuid.UUID
+	ArchivedID uuid.UUID
+}
+
+func (q *Queries) HasImportedChirp(ctx context.Context, arg HasImportedChirpParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasImportedChirp, arg.UserID, arg.ArchivedID)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const importChirp = `-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, imported_from_id)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  NOW(),
+  $2,
+  $3,
+  $4,
+  $5
+  )
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type ImportChirpParams struct {
+	CreatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	Visibility     string
+	ImportedFromID uuid.NullUUID
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, importChirp, arg.CreatedAt, arg.Body, arg.UserID, arg.Visibility, arg.ImportedFromID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const postponeScheduledChirp = `-- name: PostponeScheduledChirp :exec
+UPDATE chirps
+SET publish_at = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type PostponeScheduledChirpParams struct {
+	ID        uuid.UUID
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) PostponeScheduledChirp(ctx context.Context, arg PostponeScheduledChirpParams) error {
+	_, err := q.db.ExecContext(ctx, postponeScheduledChirp, arg.ID, arg.PublishAt)
+	return err
+}
+
+const publishDraft = `-- name: PublishDraft :one
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type PublishDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) PublishDraft(ctx context.Context, arg PublishDraftParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishDraft, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const publishScheduledChirp = `-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+func (q *Queries) PublishScheduledChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishScheduledChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < NOW() - make_interval(secs => $1::float8)
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, retentionSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, retentionSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeChirp = `-- name: RemoveChirp :one
+UPDATE chirps
+SET deleted_at = COALESCE(deleted_at, NOW()),
+    moderated_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+func (q *Queries) RemoveChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, removeChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND user_id = $2
+  AND moderated_at IS NULL
+  AND deleted_at > NOW() - make_interval(secs => $3::float8)
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type RestoreChirpParams struct {
+	ID                   uuid.UUID
+	UserID               uuid.UUID
+	RestoreWindowSeconds float64
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.RestoreWindowSeconds)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+    updated_at = NOW()
+WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL AND status = 'published'
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type UpdateChirpBodyParams struct {
+	Body   string
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
+
+const updateDraft = `-- name: UpdateDraft :one
+UPDATE chirps
+SET body = $1,
+    status = $2,
+    publish_at = $3,
+    visibility = $4,
+    updated_at = NOW()
+WHERE id = $5 AND user_id = $6 AND status IN ('draft', 'scheduled')
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, status, publish_at, visibility, reply_to_id, moderated_at, imported_from_id
+`
+
+type UpdateDraftParams struct {
+	Body       string
+	Status     string
+	PublishAt  sql.NullTime
+	Visibility string
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) UpdateDraft(ctx context.Context, arg UpdateDraftParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateDraft, arg.Body, arg.Status, arg.PublishAt, arg.Visibility, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.ReplyToID,
+		&i.ModeratedAt,
+		&i.ImportedFromID,
+	)
+	return i, err
+}
@@ -6,17 +6,184 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	DeletedAt      sql.NullTime
+	Status         string
+	PublishAt      sql.NullTime
+	Visibility     string
+	ReplyToID      uuid.NullUUID
+	ModeratedAt    sql.NullTime
+	ImportedFromID uuid.NullUUID
+}
+
+type ChirpImport struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	UserID         uuid.UUID
+	Status         string
+	Attempts       int32
+	LeaseExpiresAt sql.NullTime
+	Position       int32
+	ImportedCount  int32
+	FailedCount    int32
+	Error          sql.NullString
+	CompletedAt    sql.NullTime
+}
+
+type ChirpImportError struct {
+	ImportID uuid.UUID
+	Line     int32
+	Error    string
+}
+
+type ChirpImportUpload struct {
+	ImportID uuid.UUID
+	Data     []byte
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChirpID   uuid.UUID
 	Body      string
+}
+
+type ContentFilterRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Term      string
+	Action    string
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.NullUUID
+	IsGroup   bool
+	DirectKey sql.NullString
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type DataExport struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	UserID         uuid.UUID
+	Status         string
+	Attempts       int32
+	LeaseExpiresAt sql.NullTime
+	Error          sql.NullString
+	CompletedAt    sql.NullTime
+	ExpiresAt      sql.NullTime
+}
+
+type DataExportArchive struct {
+	ExportID uuid.UUID
+	Archive  []byte
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	KeyID          string
+	Ciphertext     []byte
+}
+
+type ModerationAction struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ModeratorID    uuid.NullUUID
+	ReportID       uuid.NullUUID
+	UserID         uuid.UUID
+	ChirpID        uuid.NullUUID
+	Action         string
+	Reason         string
+	SuspendedUntil sql.NullTime
+}
+
+type Notification struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Type          string
+	GroupKey      string
+	ChirpID       uuid.NullUUID
+	LatestActorID uuid.NullUUID
+	Data          json.RawMessage
+	ReadAt        sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type OutboxEvent struct {
+	ID           int64
+	CreatedAt    time.Time
+	EventType    string
+	UserID       uuid.UUID
+	Public       bool
+	Payload      json.RawMessage
+	DispatchedAt sql.NullTime
+	StreamSeq    sql.NullInt64
+}
+
+type Poll struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	ExpiresAt time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
 	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +195,100 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    string
+	Status     string
+	ClaimedBy  uuid.NullUUID
+	ClaimedAt  sql.NullTime
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+	Resolution sql.NullString
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+}
+
+type SubscriptionEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Event     string
+	Plan      string
+	PeriodEnd sql.NullTime
+}
+
 type User struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	Role                string
+	SuspendedUntil      sql.NullTime
+	BannedAt            sql.NullTime
+	DeletionRequestedAt sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	SubscriptionID uuid.UUID
+	EventID        int64
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          string
+	ReceivedAt  time.Time
+	UpdatedAt   time.Time
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	LastError   sql.NullString
+	ProcessedAt sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
 }
@@ -7,11 +7,37 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const banUser = `-- name: BanUser :exec
+UPDATE users
+SET banned_at = COALESCE(banned_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) BanUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, banUser, id)
+	return err
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -21,7 +47,7 @@ VALUES (
   $1,
   $2
   )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_until, banned_at, deletion_requested_at
 `
 
 type CreateUserParams struct {
@@ -39,21 +65,36 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
 const deleteAllUsers = `-- name: DeleteAllUsers :exec
 DELETE FROM users
+WHERE id <> $1
+`
+
+func (q *Queries) DeleteAllUsers(ctx context.Context, deletedUserID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteAllUsers, deletedUserID)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
 `
 
-func (q *Queries) DeleteAllUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteAllUsers)
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
 	return err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_until, banned_at, deletion_requested_at FROM users
 WHERE email = $1
 `
 
@@ -67,19 +108,172 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
-const setUserPremiumByID = `-- name: SetUserPremiumByID :exec
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_until, banned_at, deletion_requested_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const getUserIDsByEmails = `-- name: GetUserIDsByEmails :many
+SELECT id FROM users
+WHERE lower(email) = ANY($1::text[])
+`
+
+func (q *Queries) GetUserIDsByEmails(ctx context.Context, emails []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUserIDsByEmails, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersDueForPurge = `-- name: GetUsersDueForPurge :many
+SELECT id FROM users
+WHERE deletion_requested_at < NOW() - make_interval(secs => $1::float8)
+ORDER BY deletion_requested_at ASC
+LIMIT $2
+`
+
+type GetUsersDueForPurgeParams struct {
+	GracePeriodSeconds float64
+	MaxResults         int32
+}
+
+func (q *Queries) GetUsersDueForPurge(ctx context.Context, arg GetUsersDueForPurgeParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDueForPurge, arg.GracePeriodSeconds, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, suspended_until, banned_at, deletion_requested_at
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SuspendUserParams struct {
+	ID             uuid.UUID
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil)
+	return err
+}
+
+const syncUserPremium = `-- name: SyncUserPremium :execrows
+UPDATE users
+SET is_chirpy_red = EXISTS (
+      SELECT 1 FROM subscriptions
+      WHERE subscriptions.user_id = users.id
+        AND subscriptions.status = 'active'
+        AND subscriptions.current_period_end > NOW()
+    ),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SyncUserPremium(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, syncUserPremium, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unbanUser = `-- name: UnbanUser :exec
 UPDATE users
-SET is_chirpy_red = true,
+SET banned_at = NULL,
     updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) SetUserPremiumByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, setUserPremiumByID, id)
+func (q *Queries) UnbanUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unbanUser, id)
 	return err
 }
 
@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const keySize = 32
+
+// Keyring encrypts with AES-256-GCM under its current key and decrypts with
+// any key it holds, so keys can be rotated without re-encrypting old data.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// ParseKeys builds a keyring from "id:base64key" entries. The first entry
+// is the current key.
+func ParseKeys(entries []string) (*Keyring, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("no keys")
+	}
+
+	k := &Keyring{aeads: make(map[string]cipher.AEAD, len(entries))}
+	for i, entry := range entries {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key %d: want id:base64key", i+1)
+		}
+		if _, dup := k.aeads[id]; dup {
+			return nil, fmt.Errorf("key %q: duplicate id", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q: must be %d bytes", id, keySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+		if i == 0 {
+			k.current = id
+		}
+	}
+	return k, nil
+}
+
+// Encrypt seals plaintext under the current key. The additional data is
+// authenticated but not stored, and must be given again to decrypt. It
+// returns the ID of the key used and the nonce-prefixed ciphertext.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, []byte, error) {
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the key keyID.
+func (k *Keyring) Decrypt(keyID string, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
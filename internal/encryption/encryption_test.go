@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, keySize))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := ParseKeys([]string{"v1:" + testKey(1)})
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+
+	keyID, ciphertext, err := k.Encrypt([]byte("hello"), []byte("conversation"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if keyID != "v1" {
+		t.Errorf("Encrypt() keyID = %q, want v1", keyID)
+	}
+	if bytes.Contains(ciphertext, []byte("hello")) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	plaintext, err := k.Decrypt(keyID, ciphertext, []byte("conversation"))
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(plaintext) != "hello" {
+		t.Errorf("Decrypt() = %q, want hello", plaintext)
+	}
+
+	if _, err := k.Decrypt(keyID, ciphertext, []byte("other")); err == nil {
+		t.Error("Decrypt() with different additional data succeeded")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, _ := ParseKeys([]string{"v1:" + testKey(1)})
+	keyID, ciphertext, _ := old.Encrypt([]byte("hello"), nil)
+
+	rotated, err := ParseKeys([]string{"v2:" + testKey(2), "v1:" + testKey(1)})
+	if err != nil {
+		t.Fatalf("ParseKeys() error = %v", err)
+	}
+	if newID, _, _ := rotated.Encrypt([]byte("hi"), nil); newID != "v2" {
+		t.Errorf("Encrypt() after rotation used %q, want v2", newID)
+	}
+	if _, err := rotated.Decrypt(keyID, ciphertext, nil); err != nil {
+		t.Errorf("Decrypt() with old key error = %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+	}{
+		{name: "No keys", entries: nil},
+		{name: "Missing id", entries: []string{testKey(1)}},
+		{name: "Invalid base64", entries: []string{"v1:not base64"}},
+		{name: "Short key", entries: []string{"v1:" + base64.StdEncoding.EncodeToString([]byte("short"))}},
+		{name: "Duplicate id", entries: []string{"v1:" + testKey(1), "v1:" + testKey(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKeys(tt.entries); err == nil {
+				t.Error("ParseKeys() succeeded, want error")
+			}
+		})
+	}
+}
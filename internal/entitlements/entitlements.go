@@ -0,0 +1,109 @@
+package entitlements
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type Plan string
+
+const (
+	PlanFree Plan = "free"
+	PlanRed  Plan = "chirpy_red"
+)
+
+// Limits describes what a plan is allowed to do.
+type Limits struct {
+	MaxChirpLength int
+	CanEditChirps  bool
+	ChirpsPerHour  int
+}
+
+// Entitlements maps every plan to its limits.
+type Entitlements map[Plan]Limits
+
+var defaults = Entitlements{
+	PlanFree: {
+		MaxChirpLength: 140,
+		CanEditChirps:  false,
+		ChirpsPerHour:  30,
+	},
+	PlanRed: {
+		MaxChirpLength: 280,
+		CanEditChirps:  true,
+		ChirpsPerHour:  300,
+	},
+}
+
+var envPrefixes = map[Plan]string{
+	PlanFree: "FREE",
+	PlanRed:  "RED",
+}
+
+// PlanFor returns the plan a user is on.
+func PlanFor(isChirpyRed bool) Plan {
+	if isChirpyRed {
+		return PlanRed
+	}
+	return PlanFree
+}
+
+// For returns the limits for plan, falling back to the free plan for
+// unknown plans.
+func (e Entitlements) For(plan Plan) Limits {
+	if limits, ok := e[plan]; ok {
+		return limits
+	}
+	return e[PlanFree]
+}
+
+// Load builds the entitlements from environment variables, using the
+// defaults for anything unset. Variables are named after the plan, e.g.
+// FREE_MAX_CHIRP_LENGTH or RED_CHIRPS_PER_HOUR.
+func Load(getenv func(string) string) (Entitlements, error) {
+	e := make(Entitlements, len(defaults))
+	for plan, limits := range defaults {
+		prefix := envPrefixes[plan]
+
+		var err error
+		limits.MaxChirpLength, err = positiveInt(getenv, prefix+"_MAX_CHIRP_LENGTH", limits.MaxChirpLength)
+		if err != nil {
+			return nil, err
+		}
+		limits.ChirpsPerHour, err = positiveInt(getenv, prefix+"_CHIRPS_PER_HOUR", limits.ChirpsPerHour)
+		if err != nil {
+			return nil, err
+		}
+		limits.CanEditChirps, err = boolean(getenv, prefix+"_CAN_EDIT_CHIRPS", limits.CanEditChirps)
+		if err != nil {
+			return nil, err
+		}
+
+		e[plan] = limits
+	}
+	return e, nil
+}
+
+func positiveInt(getenv func(string) string, key string, fallback int) (int, error) {
+	value := getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be a positive integer", key)
+	}
+	return n, nil
+}
+
+func boolean(getenv func(string) string, key string, fallback bool) (bool, error) {
+	value := getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,71 @@
+package entitlements
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		plan    Plan
+		want    Limits
+		wantErr bool
+	}{
+		{
+			name: "Defaults for free plan",
+			env:  map[string]string{},
+			plan: PlanFree,
+			want: defaults[PlanFree],
+		},
+		{
+			name: "Defaults for red plan",
+			env:  map[string]string{},
+			plan: PlanRed,
+			want: defaults[PlanRed],
+		},
+		{
+			name: "Overrides from environment",
+			env: map[string]string{
+				"RED_MAX_CHIRP_LENGTH": "500",
+				"RED_CHIRPS_PER_HOUR":  "1000",
+				"RED_CAN_EDIT_CHIRPS":  "false",
+			},
+			plan: PlanRed,
+			want: Limits{MaxChirpLength: 500, CanEditChirps: false, ChirpsPerHour: 1000},
+		},
+		{
+			name:    "Invalid length",
+			env:     map[string]string{"FREE_MAX_CHIRP_LENGTH": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid boolean",
+			env:     map[string]string{"FREE_CAN_EDIT_CHIRPS": "maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(func(key string) string { return tt.env[key] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if limits := got.For(tt.plan); limits != tt.want {
+				t.Errorf("Load().For(%q) = %+v, want %+v", tt.plan, limits, tt.want)
+			}
+		})
+	}
+}
+
+func TestForUnknownPlan(t *testing.T) {
+	e, err := Load(func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := e.For("platinum"); got != e.For(PlanFree) {
+		t.Errorf("For(unknown) = %+v, want free plan limits", got)
+	}
+}
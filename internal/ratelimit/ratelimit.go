@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is an in-memory fixed-window rate limiter keyed by an arbitrary
+// string. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func New(w time.Duration) *Limiter {
+	return &Limiter{
+		window:  w,
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it fits within limit
+// events per window. When it doesn't, it also returns how long until the
+// window resets.
+func (l *Limiter) Allow(key string, limit int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// Release gives back an event recorded by Allow, for when the action it
+// counted didn't happen after all. It does nothing once the window the event
+// was counted in has reset.
+func (l *Limiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || l.now().Sub(w.start) >= l.window || w.count == 0 {
+		return
+	}
+	w.count--
+}
+
+// sweep drops expired windows so idle keys don't accumulate.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
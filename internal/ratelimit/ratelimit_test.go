@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(time.Hour)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("user", 3); !ok {
+			t.Fatalf("Allow() call %d rejected, want allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("user", 3)
+	if ok {
+		t.Fatal("Allow() over the limit was allowed")
+	}
+	if retryAfter != time.Hour {
+		t.Errorf("Allow() retryAfter = %v, want %v", retryAfter, time.Hour)
+	}
+
+	if ok, _ := l.Allow("other", 3); !ok {
+		t.Error("Allow() for a different key was rejected")
+	}
+
+	now = now.Add(time.Hour)
+	if ok, _ := l.Allow("user", 3); !ok {
+		t.Error("Allow() after the window reset was rejected")
+	}
+}
+
+func TestRelease(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(time.Hour)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("user", 2); !ok {
+			t.Fatalf("Allow() call %d rejected, want allowed", i+1)
+		}
+	}
+	l.Release("user")
+	if ok, _ := l.Allow("user", 2); !ok {
+		t.Fatal("Allow() after Release() was rejected")
+	}
+	if ok, _ := l.Allow("user", 2); ok {
+		t.Fatal("Allow() over the limit after Release() was allowed")
+	}
+
+	// Releasing an unknown key or an expired window is a no-op.
+	l.Release("other")
+	now = now.Add(time.Hour)
+	l.Release("user")
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("user", 2); !ok {
+			t.Fatalf("Allow() call %d in a new window rejected, want allowed", i+1)
+		}
+	}
+	if ok, _ := l.Allow("user", 2); ok {
+		t.Error("Release() of an expired window carried over into the new one")
+	}
+}
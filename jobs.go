@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// runPeriodically calls job once per interval until ctx is cancelled. A run
+// that is in progress when ctx is cancelled is allowed to finish.
+func runPeriodically(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// workerGroup runs the background workers so they can be stopped together
+// on shutdown.
+type workerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go runs worker in its own goroutine. Its context is cancelled by Stop.
+func (g *workerGroup) Go(worker func(context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		worker(g.ctx)
+	}()
+}
+
+// Every runs job once per interval until the group is stopped.
+func (g *workerGroup) Every(interval time.Duration, job func(context.Context)) {
+	g.Go(func(ctx context.Context) {
+		runPeriodically(ctx, interval, job)
+	})
+}
+
+// Stop cancels the workers and waits for them to return, giving up when ctx
+// is done.
+func (g *workerGroup) Stop(ctx context.Context) error {
+	g.cancel()
+	return waitGroupContext(ctx, &g.wg)
+}
+
+// waitGroupContext waits for wg, giving up when ctx is done.
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
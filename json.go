@@ -2,22 +2,55 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
 	"net/http"
+	"unicode/utf8"
+
+	"github.com/santokan/go-httpserver/internal/chirptext"
 )
 
+// decodeTextRequest decodes a JSON request body carrying user-written text.
+// encoding/json silently replaces invalid UTF-8 with U+FFFD, so the raw body
+// is checked first and refused with chirptext.ErrInvalidUTF8.
+func decodeTextRequest(r *http.Request, v any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(body) {
+		return chirptext.ErrInvalidUTF8
+	}
+	return json.Unmarshal(body, v)
+}
+
+// respondWithInvalidPayload responds to a body decodeTextRequest couldn't
+// decode.
+func respondWithInvalidPayload(w http.ResponseWriter, err error) {
+	if errors.Is(err, chirptext.ErrInvalidUTF8) {
+		respondWithError(w, http.StatusBadRequest, "Body is not valid UTF-8", err)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+}
+
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
+	attrs := []any{"status", code, "message", msg}
 	if err != nil {
-		log.Println(err)
+		attrs = append(attrs, "error", err)
 	}
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		loggerFor(w).Error("Responding with 5XX error", attrs...)
+	} else if err != nil {
+		loggerFor(w).Info("Responding with error", attrs...)
 	}
 	type errorResponse struct {
-		Error string `json:"error"`
+		Error   string `json:"error"`
+		TraceID string `json:"trace_id,omitempty"`
 	}
 	respondWithJSON(w, code, errorResponse{
-		Error: msg,
+		Error:   msg,
+		TraceID: traceIDFor(w),
 	})
 }
 
@@ -25,7 +58,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		loggerFor(w).Error("Error marshalling JSON", "error", err)
 		w.WriteHeader(500)
 		return
 	}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// maxRequestIDLength caps the length of a request ID taken from the
+// X-Request-ID header.
+const maxRequestIDLength = 128
+
+// newLogger builds a logger writing to out in the given format ("text" or
+// "json") at the given level ("debug", "info", "warn" or "error").
+func newLogger(out io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch format {
+	case "", logFormatText:
+		return slog.New(slog.NewTextHandler(out, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(out, opts)), nil
+	default:
+		return nil, fmt.Errorf("log format must be %q or %q", logFormatText, logFormatJSON)
+	}
+}
+
+// fatal logs an error and exits. It is only for startup failures.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// loggingResponseWriter records the response status and size for the
+// request log line, and carries the request's logger so responses written
+// through it can be correlated with their request.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	logger  *slog.Logger
+	traceID string
+	status  int
+	bytes   int
+}
+
+func (w *loggingResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (w *loggingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController and the WebSocket upgrade reach the
+// underlying writer.
+func (w *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// loggerFor returns the logger for the request w is responding to.
+func loggerFor(w http.ResponseWriter) *slog.Logger {
+	if lw, ok := w.(*loggingResponseWriter); ok {
+		return lw.logger
+	}
+	return slog.Default()
+}
+
+// traceIDFor returns the trace ID of the request w is responding to, or ""
+// if it isn't traced.
+func traceIDFor(w http.ResponseWriter) string {
+	if lw, ok := w.(*loggingResponseWriter); ok {
+		return lw.traceID
+	}
+	return ""
+}
+
+// setRequestUserID adds the authenticated user to the request's log lines.
+func setRequestUserID(w http.ResponseWriter, userID uuid.UUID) {
+	if lw, ok := w.(*loggingResponseWriter); ok {
+		lw.logger = lw.logger.With("user_id", userID)
+	}
+}
+
+// middlewareLogging gives every request an ID and a logger carrying it, and
+// logs one line per request once the response is written. The request ID
+// comes from the X-Request-ID header when the client sends one and is
+// echoed back in the response. Requests that are part of a trace also log
+// its trace_id.
+func middlewareLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		// middlewareTracing may already have wrapped the writer.
+		lw, ok := w.(*loggingResponseWriter)
+		if !ok {
+			lw = &loggingResponseWriter{ResponseWriter: w}
+		}
+		lw.logger = slog.Default().With("request_id", requestID)
+		if id := traceID(r.Context()); id != "" {
+			lw.traceID = id
+			lw.logger = lw.logger.With("trace_id", id)
+		}
+
+		next.ServeHTTP(lw, r)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		lw.logger.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"route", r.Pattern,
+			"path", r.URL.Path,
+			"status", status,
+			"latency", time.Since(start),
+			"bytes", lw.bytes,
+		)
+	})
+}
@@ -1,57 +1,342 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/broker"
+	"github.com/santokan/go-httpserver/internal/contentfilter"
 	"github.com/santokan/go-httpserver/internal/database"
+	"github.com/santokan/go-httpserver/internal/encryption"
+	"github.com/santokan/go-httpserver/internal/entitlements"
+	"github.com/santokan/go-httpserver/internal/ratelimit"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	secret         string
-	apiKey         string
+	metrics  *metrics
+	db       *database.Queries
+	dbConn   *sql.DB
+	platform string
+	secret   string
+	apiKey   string
+
+	polkaWebhookSecrets     []string
+	polkaSignatureTolerance time.Duration
+
+	chirpRestoreWindow time.Duration
+	chirpRetention     time.Duration
+
+	entitlements       entitlements.Entitlements
+	chirpLimiter       *ratelimit.Limiter
+	subscriptionPeriod time.Duration
+
+	broker        *broker.Broker
+	streamBackend string
+	// outboxWake asks the outbox dispatcher to run without waiting for its
+	// next tick.
+	outboxWake chan struct{}
+	// outboxRetention is how long dispatched outbox events and finished
+	// webhook deliveries are kept.
+	outboxRetention time.Duration
+	// streamReplayWindow is how far back a resuming SSE client is sent the
+	// events it missed.
+	streamReplayWindow time.Duration
+
+	messageKeys *encryption.Keyring
+
+	contentFilter     atomic.Pointer[contentfilter.Filter]
+	contentFilterFile string
+
+	accountDeletionGracePeriod time.Duration
+
+	exportLinkTTL   time.Duration
+	exportRetention time.Duration
+	// exportSigningKey signs export download links. It is derived from
+	// secret but distinct from the key JWTs are signed with.
+	exportSigningKey string
+
+	// importChirpsPerHour is how many chirps an import may add per hour,
+	// counted apart from the chirp rate limit.
+	importChirpsPerHour int
+
+	// draining is closed when the server starts shutting down, telling open
+	// SSE and WebSocket connections to close.
+	draining chan struct{}
+	// streams tracks open SSE and WebSocket connections. WebSocket
+	// connections are hijacked, so Server.Shutdown doesn't wait for them.
+	streams sync.WaitGroup
 }
 
 func main() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		fatal("Error loading .env file", "error", err)
+	}
+
+	logger, err := newLogger(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fatal("Invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := setupTracing(context.Background(), os.Getenv("TRACE_EXPORTER"))
+	if err != nil {
+		fatal("Invalid tracing configuration", "error", err)
 	}
 
 	dbURL := os.Getenv("DB_URL")
 	if dbURL == "" {
-		log.Fatal("DB_URL environment variable is not set")
+		fatal("DB_URL environment variable is not set")
 	}
 
 	platform := os.Getenv("PLATFORM")
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		fatal("Error opening database", "error", err)
 	}
 
 	secret := os.Getenv("SECRET")
 	apiKey := os.Getenv("POLKA_KEY")
+	polkaWebhookSecrets := getEnvList("POLKA_WEBHOOK_SECRETS")
+	polkaSignatureTolerance, err := getEnvDuration("POLKA_SIGNATURE_TOLERANCE", 5*time.Minute)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	chirpRestoreWindow, err := getEnvDuration("CHIRP_RESTORE_WINDOW", 7*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	chirpRetention, err := getEnvDuration("CHIRP_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	if chirpRestoreWindow > chirpRetention {
+		fatal("CHIRP_RESTORE_WINDOW must not exceed CHIRP_RETENTION")
+	}
+	chirpPurgeInterval, err := getEnvDuration("CHIRP_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	chirpSchedulerInterval, err := getEnvDuration("CHIRP_SCHEDULER_INTERVAL", 30*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	subscriptionPeriod, err := getEnvDuration("SUBSCRIPTION_PERIOD", 30*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	subscriptionExpiryInterval, err := getEnvDuration("SUBSCRIPTION_EXPIRY_INTERVAL", time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	webhookRetryInterval, err := getEnvDuration("WEBHOOK_RETRY_INTERVAL", 5*time.Minute)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	outboxDispatchInterval, err := getEnvDuration("OUTBOX_DISPATCH_INTERVAL", 5*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	outboxRetention, err := getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	outboxPruneInterval, err := getEnvDuration("OUTBOX_PRUNE_INTERVAL", time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	streamReplayWindow, err := getEnvDuration("STREAM_REPLAY_WINDOW", 24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	if streamReplayWindow > outboxRetention {
+		fatal("STREAM_REPLAY_WINDOW must not exceed OUTBOX_RETENTION")
+	}
+	webhookDeliveryInterval, err := getEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	webhookDeliveryWorkers, err := getEnvInt("WEBHOOK_DELIVERY_WORKERS", 4)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	streamBackend := os.Getenv("STREAM_BACKEND")
+	switch streamBackend {
+	case "":
+		streamBackend = streamBackendMemory
+	case streamBackendMemory, streamBackendPostgres:
+	default:
+		fatal("STREAM_BACKEND must be " + streamBackendMemory + " or " + streamBackendPostgres)
+	}
+
+	var messageKeys *encryption.Keyring
+	if keys := getEnvList("MESSAGE_ENCRYPTION_KEYS"); len(keys) > 0 {
+		messageKeys, err = encryption.ParseKeys(keys)
+		if err != nil {
+			fatal("Invalid MESSAGE_ENCRYPTION_KEYS", "error", err)
+		}
+	} else {
+		slog.Warn("MESSAGE_ENCRYPTION_KEYS is not set; direct messages are disabled")
+	}
+
+	contentFilterReloadInterval, err := getEnvDuration("CONTENT_FILTER_RELOAD_INTERVAL", time.Minute)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	accountDeletionGracePeriod, err := getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	accountPurgeInterval, err := getEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	exportInterval, err := getEnvDuration("EXPORT_INTERVAL", 10*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	exportLinkTTL, err := getEnvDuration("EXPORT_LINK_TTL", time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	exportRetention, err := getEnvDuration("EXPORT_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	exportSigningKey, err := auth.DeriveKey(secret, "export-url")
+	if err != nil {
+		fatal("Error deriving export signing key", "error", err)
+	}
+
+	importInterval, err := getEnvDuration("IMPORT_INTERVAL", 10*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	importChirpsPerHour, err := getEnvInt("IMPORT_CHIRPS_PER_HOUR", 5000)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	readHeaderTimeout, err := getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	readTimeout, err := getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	writeTimeout, err := getEnvDuration("SERVER_WRITE_TIMEOUT", time.Minute)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	idleTimeout, err := getEnvDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	maxHeaderBytes, err := getEnvInt("SERVER_MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	planEntitlements, err := entitlements.Load(os.Getenv)
+	if err != nil {
+		fatal("Error loading entitlements", "error", err)
+	}
 
 	const (
 		filePathRoot = "."
 		port         = "8080"
 	)
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(tracedDB{db})
 	apiCfg := &apiConfig{
+		metrics:  newMetrics(db),
 		db:       dbQueries,
+		dbConn:   db,
 		platform: platform,
 		secret:   secret,
 		apiKey:   apiKey,
+
+		polkaWebhookSecrets:     polkaWebhookSecrets,
+		polkaSignatureTolerance: polkaSignatureTolerance,
+
+		chirpRestoreWindow: chirpRestoreWindow,
+		chirpRetention:     chirpRetention,
+
+		entitlements:       planEntitlements,
+		chirpLimiter:       ratelimit.New(time.Hour),
+		subscriptionPeriod: subscriptionPeriod,
+
+		broker:        broker.New(64),
+		streamBackend: streamBackend,
+		outboxWake:    make(chan struct{}, 1),
+
+		outboxRetention:    outboxRetention,
+		streamReplayWindow: streamReplayWindow,
+
+		messageKeys: messageKeys,
+
+		contentFilterFile: os.Getenv("CONTENT_FILTER_FILE"),
+
+		accountDeletionGracePeriod: accountDeletionGracePeriod,
+
+		exportLinkTTL:    exportLinkTTL,
+		exportRetention:  exportRetention,
+		exportSigningKey: exportSigningKey,
+
+		importChirpsPerHour: importChirpsPerHour,
+
+		draining: make(chan struct{}),
+	}
+
+	ctx := context.Background()
+	if err := apiCfg.reloadContentFilter(ctx); err != nil {
+		fatal("Error loading content filter", "error", err)
+	}
+
+	workers := newWorkerGroup()
+	workers.Every(chirpPurgeInterval, apiCfg.purgeDeletedChirps)
+	workers.Every(chirpSchedulerInterval, apiCfg.publishScheduledChirps)
+	workers.Every(subscriptionExpiryInterval, apiCfg.expireLapsedSubscriptions)
+	workers.Every(webhookRetryInterval, apiCfg.retryFailedWebhookEvents)
+	workers.Go(func(ctx context.Context) {
+		apiCfg.runOutboxDispatcher(ctx, outboxDispatchInterval)
+	})
+	workers.Every(outboxPruneInterval, apiCfg.pruneOutbox)
+	workers.Every(contentFilterReloadInterval, apiCfg.refreshContentFilter)
+	workers.Every(accountPurgeInterval, apiCfg.purgeDeletedAccounts)
+	workers.Every(exportInterval, apiCfg.processDataExports)
+	workers.Every(importInterval, apiCfg.processChirpImports)
+	workers.Go(func(ctx context.Context) {
+		apiCfg.runWebhookWorkers(ctx, webhookDeliveryWorkers, webhookDeliveryInterval)
+	})
+	if streamBackend == streamBackendPostgres {
+		workers.Go(func(ctx context.Context) {
+			if err := apiCfg.listenForEvents(ctx, dbURL); err != nil {
+				fatal("Error listening for events", "error", err)
+			}
+		})
 	}
 
 	mux := http.NewServeMux()
@@ -59,22 +344,106 @@ func main() {
 	mux.HandleFunc("GET /api/healthz", handlerReady)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handerMetrics)
+	mux.Handle("GET /metrics", apiCfg.metrics.handler())
+	mux.HandleFunc("GET /admin/webhooks/events", apiCfg.handlerListWebhookEvents)
+	mux.HandleFunc("GET /admin/webhooks/events/{eventID}", apiCfg.handlerGetWebhookEvent)
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.handlerReplayWebhookEvent)
+	mux.HandleFunc("GET /admin/content-filter/rules", apiCfg.handlerListContentFilterRules)
+	mux.HandleFunc("POST /admin/content-filter/rules", apiCfg.handlerCreateContentFilterRule)
+	mux.HandleFunc("PUT /admin/content-filter/rules/{ruleID}", apiCfg.handlerUpdateContentFilterRule)
+	mux.HandleFunc("DELETE /admin/content-filter/rules/{ruleID}", apiCfg.handlerDeleteContentFilterRule)
+	mux.HandleFunc("POST /admin/content-filter/reload", apiCfg.handlerReloadContentFilter)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerListReports)
+	mux.HandleFunc("GET /admin/reports/{reportID}", apiCfg.handlerGetReport)
+	mux.HandleFunc("POST /admin/reports/{reportID}/claim", apiCfg.handlerClaimReport)
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.handlerResolveReport)
+	mux.HandleFunc("GET /admin/users/{userID}/moderation", apiCfg.handlerGetUserModerationActions)
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", apiCfg.handlerModerateUser(moderationActionSuspend))
+	mux.HandleFunc("POST /admin/users/{userID}/unsuspend", apiCfg.handlerModerateUser(moderationActionUnsuspend))
+	mux.HandleFunc("POST /admin/users/{userID}/ban", apiCfg.handlerModerateUser(moderationActionBan))
+	mux.HandleFunc("POST /admin/users/{userID}/unban", apiCfg.handlerModerateUser(moderationActionUnban))
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("DELETE /api/users/me", apiCfg.handlerDeleteAccount)
+	mux.HandleFunc("POST /api/users/me/export", apiCfg.handlerCreateDataExport)
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", apiCfg.handlerGetDataExport)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", apiCfg.handlerDownloadDataExport)
+	mux.HandleFunc("POST /api/users/me/import", apiCfg.handlerCreateChirpImport)
+	mux.HandleFunc("GET /api/users/me/import/{importID}", apiCfg.handlerGetChirpImport)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.handlerBlockUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.handlerUnblockUser)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.handlerMuteUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.handlerUnmuteUser)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetAllChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerRestoreChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.handlerVotePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/reports", apiCfg.handlerCreateReport)
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerCreateConversation)
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerGetMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerSendMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerMarkConversationRead)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerMarkNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerMarkNotificationRead)
+	mux.HandleFunc("GET /api/stream/chirps", apiCfg.handlerStreamChirps)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerGetDrafts)
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.handlerGetDraft)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.handlerPublishDraft)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerWebhooks)
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerCreateWebhookSubscription)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhookSubscriptions)
+	mux.HandleFunc("DELETE /api/webhooks/{subscriptionID}", apiCfg.handlerDeleteWebhookSubscription)
+	mux.HandleFunc("GET /api/webhooks/{subscriptionID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+	mux.HandleFunc("POST /api/webhooks/{subscriptionID}/deliveries/{deliveryID}/retry", apiCfg.handlerRetryWebhookDelivery)
 
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + port,
+		Handler:           middlewareTracing(middlewareLogging(apiCfg.metrics.middleware(apiCfg.middlewareAccountStatus(mux)))),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	server.RegisterOnShutdown(func() { close(apiCfg.draining) })
+
+	signals, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving files", "root", filePathRoot, "addr", "http://localhost:"+port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		fatal("Server stopped", "error", err)
+	case <-signals.Done():
 	}
+	// A second signal kills the process without waiting.
+	stop()
 
-	log.Printf("Serving files from '%s' on http://localhost:%s", filePathRoot, port)
-	log.Fatal(server.ListenAndServe())
+	slog.Info("Shutting down", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	apiCfg.shutdown(shutdownCtx, server, workers, shutdownTracing)
+	slog.Info("Server stopped")
 }
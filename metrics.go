@@ -1,15 +1,198 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "chirpy"
+
+// Values of the source label on chirpy_chirps_created_total.
+const (
+	chirpSourceAPI       = "api"
+	chirpSourceDraft     = "draft"
+	chirpSourceScheduled = "scheduled"
+	chirpSourceImport    = "import"
+)
+
+// Values of the result label on chirpy_webhook_events_total.
+const (
+	webhookResultProcessed    = "processed"
+	webhookResultDuplicate    = "duplicate"
+	webhookResultUserNotFound = "user_not_found"
+	webhookResultFailed       = "failed"
 )
 
+// metrics holds the Prometheus registry served on /metrics and the
+// collectors the server updates.
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	httpResponseSize *prometheus.HistogramVec
+
+	fileserverHits prometheus.Counter
+	// fileserverHitsAtReset is the hit count when /admin/reset last ran.
+	// Prometheus counters only go up, so the admin page shows the hits
+	// since then rather than resetting the counter.
+	fileserverHitsAtReset atomic.Uint64
+
+	chirpsCreated *prometheus.CounterVec
+	logins        prometheus.Counter
+	failedLogins  prometheus.Counter
+	webhookEvents *prometheus.CounterVec
+}
+
+// newMetrics builds a registry with the HTTP and business metrics, the Go
+// runtime and process collectors, and the connection pool stats of db.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpResponseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 6),
+		}, []string{"method", "route"}),
+		fileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "fileserver_hits_total",
+			Help:      "Requests for files under /app/.",
+		}),
+		chirpsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps published, by how they were created.",
+		}, []string{"source"}),
+		logins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Successful logins.",
+		}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "login_failures_total",
+			Help:      "Logins rejected for a wrong email or password.",
+		}),
+		webhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "webhook_events_total",
+			Help:      "Polka webhook events received, by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "chirpy"),
+		m.httpRequests,
+		m.httpDuration,
+		m.httpResponseSize,
+		m.fileserverHits,
+		m.chirpsCreated,
+		m.logins,
+		m.failedLogins,
+		m.webhookEvents,
+	)
+	return m
+}
+
+// middleware records the count, latency and response size of every request,
+// labelled with the ServeMux pattern that matched it.
+func (m *metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Reuse the writer from middlewareLogging when there is one, so
+		// the response is only wrapped once.
+		lw, ok := w.(*loggingResponseWriter)
+		if !ok {
+			lw = &loggingResponseWriter{ResponseWriter: w, logger: loggerFor(w)}
+		}
+
+		next.ServeHTTP(lw, r)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Requests that match no route are grouped together so arbitrary
+		// paths and methods can't create new series.
+		method, route := r.Method, r.Pattern
+		if route == "" {
+			method, route = "other", "unmatched"
+		}
+		m.httpRequests.WithLabelValues(method, route, fmt.Sprint(status)).Inc()
+		m.httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		m.httpResponseSize.WithLabelValues(method, route).Observe(float64(lw.bytes))
+	})
+}
+
+// handler serves the registry in the Prometheus text exposition format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// fileserverHitsSinceReset reads the fileserver hit counter back from the
+// registry.
+func (m *metrics) fileserverHitsSinceReset() (uint64, error) {
+	hits, err := m.fileserverHitsTotal()
+	if err != nil {
+		return 0, err
+	}
+	return hits - m.fileserverHitsAtReset.Load(), nil
+}
+
+func (m *metrics) resetFileserverHits() error {
+	hits, err := m.fileserverHitsTotal()
+	if err != nil {
+		return err
+	}
+	m.fileserverHitsAtReset.Store(hits)
+	return nil
+}
+
+func (m *metrics) fileserverHitsTotal() (uint64, error) {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return 0, err
+	}
+	for _, family := range families {
+		if family.GetName() != metricsNamespace+"_fileserver_hits_total" {
+			continue
+		}
+		var total float64
+		for _, metric := range family.GetMetric() {
+			total += metric.GetCounter().GetValue()
+		}
+		return uint64(total), nil
+	}
+	return 0, nil
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
@@ -18,17 +201,22 @@ func (cfg *apiConfig) handerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	htmlTemplate, err := os.ReadFile("admin/metrics.html")
 	if err != nil {
-		log.Printf("Error reading admin template: %v", err)
+		loggerFor(w).Error("Error reading admin template", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	hits := cfg.fileserverHits.Load()
+	hits, err := cfg.metrics.fileserverHitsSinceReset()
+	if err != nil {
+		loggerFor(w).Error("Error gathering metrics", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 	htmlContent := fmt.Sprintf(string(htmlTemplate), hits)
 
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write([]byte(htmlContent))
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
+		loggerFor(w).Error("Error writing response", "error", err)
 	}
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	notificationTypeLike         = "like"
+	notificationTypeReply        = "reply"
+	notificationTypeMention      = "mention"
+	notificationTypeFollow       = "follow"
+	notificationTypeSubscription = "subscription"
+	notificationTypeModeration   = "moderation"
+)
+
+// maxChirpMentions is how many mentioned users a chirp notifies. Further
+// mentions are left as text.
+const maxChirpMentions = 10
+
+// mentionPattern matches an @ followed by an email address, as in
+// "thanks @alice@example.com". The @ must start a word.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.+-])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// eventNotification is recorded in the outbox for every new or updated
+// notification so live connections can push it to the recipient.
+const eventNotification = "notification"
+
+type Notification struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Type          string          `json:"type"`
+	Message       string          `json:"message"`
+	ChirpID       *uuid.UUID      `json:"chirp_id,omitempty"`
+	LatestActorID *uuid.UUID      `json:"latest_actor_id,omitempty"`
+	ActorCount    int64           `json:"actor_count"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	ReadAt        *time.Time      `json:"read_at"`
+}
+
+type subscriptionNotification struct {
+	Event string `json:"event"`
+	Plan  string `json:"plan"`
+}
+
+type moderationNotification struct {
+	Action         string     `json:"action"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+func notificationFromDB(n database.Notification, actorCount int64) Notification {
+	resp := Notification{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Type:       n.Type,
+		ActorCount: actorCount,
+	}
+	if n.ChirpID.Valid {
+		resp.ChirpID = &n.ChirpID.UUID
+	}
+	if n.LatestActorID.Valid {
+		resp.LatestActorID = &n.LatestActorID.UUID
+	}
+	if string(n.Data) != "{}" {
+		resp.Data = n.Data
+	}
+	if n.ReadAt.Valid {
+		resp.ReadAt = &n.ReadAt.Time
+	}
+	resp.Message = notificationMessage(resp.Type, actorCount, n.Data)
+	return resp
+}
+
+// notificationMessage describes a notification, counting everyone whose
+// activity was coalesced into it.
+func notificationMessage(notificationType string, actorCount int64, data json.RawMessage) string {
+	people := "1 person"
+	if actorCount != 1 {
+		people = fmt.Sprintf("%d people", actorCount)
+	}
+
+	switch notificationType {
+	case notificationTypeLike:
+		return people + " liked your chirp"
+	case notificationTypeReply:
+		return people + " replied to your chirp"
+	case notificationTypeMention:
+		return people + " mentioned you in a chirp"
+	case notificationTypeFollow:
+		return people + " followed you"
+	case notificationTypeSubscription:
+		var sub subscriptionNotification
+		json.Unmarshal(data, &sub)
+		switch sub.Event {
+		case polkaEventUserUpgraded:
+			return "Your Chirpy Red subscription has started"
+		case polkaEventSubscriptionRenewed:
+			return "Your Chirpy Red subscription was renewed"
+		case polkaEventUserDowngraded:
+			return "Your Chirpy Red subscription was cancelled"
+		case polkaEventSubscriptionExpired:
+			return "Your Chirpy Red subscription has expired"
+		}
+		return "Your subscription has changed"
+	case notificationTypeModeration:
+		var mod moderationNotification
+		json.Unmarshal(data, &mod)
+		switch mod.Action {
+		case moderationActionRemoveChirp:
+			return "A moderator removed your chirp"
+		case moderationActionWarn:
+			return "You have received a warning from a moderator"
+		case moderationActionSuspend:
+			return "Your account has been suspended"
+		case moderationActionBan:
+			return "Your account has been banned"
+		}
+		return "A moderator has taken action on your account"
+	}
+	return ""
+}
+
+type notificationParams struct {
+	UserID  uuid.UUID
+	Type    string
+	ChirpID uuid.NullUUID
+	ActorID uuid.NullUUID
+	Data    any
+	// GroupKey overrides the default grouping by type and chirp. A unique
+	// key keeps the notification from being coalesced with others.
+	GroupKey string
+}
+
+// notify creates a notification for the recipient, or coalesces it into the
+// recipient's unread notification of the same type about the same chirp.
+// Nothing is created when the recipient has muted or is blocked with the
+// actor, in which case the returned event is nil. The returned outbox event
+// should be published once q's transaction has committed.
+func notify(ctx context.Context, q *database.Queries, p notificationParams) (*database.OutboxEvent, error) {
+	if p.ActorID.Valid {
+		hidden, err := q.IsUserHidden(ctx, database.IsUserHiddenParams{
+			ViewerID: p.UserID,
+			UserID:   p.ActorID.UUID,
+		})
+		if err != nil || hidden {
+			return nil, err
+		}
+	}
+
+	groupKey := p.GroupKey
+	if groupKey == "" {
+		groupKey = p.Type
+		if p.ChirpID.Valid {
+			groupKey += ":" + p.ChirpID.UUID.String()
+		}
+	}
+
+	data := json.RawMessage("{}")
+	if p.Data != nil {
+		var err error
+		data, err = json.Marshal(p.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := q.UpsertNotification(ctx, database.UpsertNotificationParams{
+		UserID:        p.UserID,
+		Type:          p.Type,
+		GroupKey:      groupKey,
+		ChirpID:       p.ChirpID,
+		LatestActorID: p.ActorID,
+		Data:          data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.ActorID.Valid {
+		err = q.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: n.ID,
+			ActorID:        p.ActorID.UUID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	actorCount, err := q.CountNotificationActors(ctx, n.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := recordEvent(ctx, q, eventNotification, p.UserID, false, notificationFromDB(n, actorCount))
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// mentionsIn returns the lowercased email addresses mentioned in body,
+// without the leading @, in the order they first appear.
+func mentionsIn(body string) []string {
+	var emails []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(match[1])
+		if !slices.Contains(emails, email) {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// mentionedUserIDs returns the users mentioned in a chirp by authorID who
+// can see it, leaving out the author. Only the first maxChirpMentions
+// mentions are looked up.
+func (cfg *apiConfig) mentionedUserIDs(ctx context.Context, body string, authorID uuid.UUID, visibility string) ([]uuid.UUID, error) {
+	emails := mentionsIn(body)
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	if len(emails) > maxChirpMentions {
+		emails = emails[:maxChirpMentions]
+	}
+
+	ids, err := cfg.db.GetUserIDsByEmails(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	chirp := database.Chirp{UserID: authorID, Visibility: visibility}
+	var mentioned []uuid.UUID
+	for _, id := range ids {
+		if id == authorID {
+			continue
+		}
+		canView, err := cfg.canViewChirp(ctx, chirp, id)
+		if err != nil {
+			return nil, err
+		}
+		if canView {
+			mentioned = append(mentioned, id)
+		}
+	}
+	return mentioned, nil
+}
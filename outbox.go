@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpDeleted   = "chirp.deleted"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+)
+
+// eventAccountRestricted is recorded when a user is suspended or banned so
+// their open SSE streams and WebSocket connections are closed. It isn't
+// offered to webhook subscriptions.
+const eventAccountRestricted = "account.restricted"
+
+// outgoingEventTypes lists the events integrators can subscribe to.
+var outgoingEventTypes = []string{
+	eventChirpCreated,
+	eventChirpDeleted,
+	eventUserUpgraded,
+	eventUserDowngraded,
+}
+
+const outboxBatchSize = 100
+
+type chirpDeletedEvent struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Visibility string    `json:"visibility"`
+}
+
+type accountRestrictedEvent struct {
+	UserID uuid.UUID `json:"user_id"`
+	Action string    `json:"action"`
+}
+
+type userPlanEvent struct {
+	UserID uuid.UUID `json:"user_id"`
+	Plan   string    `json:"plan"`
+}
+
+// recordEvent writes an event to the outbox using q, which should be the
+// transaction that makes the change the event describes. Public events are
+// delivered to every subscriber; the rest only reach subscriptions owned by
+// userID.
+func recordEvent(ctx context.Context, q *database.Queries, eventType string, userID uuid.UUID, public bool, data any) (database.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return database.OutboxEvent{}, err
+	}
+	return q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Public:    public,
+		Payload:   payload,
+	})
+}
+
+// recordChirpCreated records a chirp.created event. Only public chirps are
+// announced to other users' subscriptions.
+func recordChirpCreated(ctx context.Context, q *database.Queries, chirp Chirp) (database.OutboxEvent, error) {
+	return recordEvent(ctx, q, eventChirpCreated, chirp.UserID, chirp.Visibility == chirpVisibilityPublic, chirp)
+}
+
+// runOutboxDispatcher dispatches the outbox once per interval, and as soon
+// as publishEvents reports new events, until ctx is cancelled.
+func (cfg *apiConfig) runOutboxDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.outboxWake:
+		}
+		cfg.dispatchOutbox(context.WithoutCancel(ctx))
+	}
+}
+
+// dispatchOutbox fans undispatched outbox events out into one pending
+// delivery per matching webhook subscription, then hands them to live
+// streams.
+//
+// Outbox IDs are allocated when events are written, so they can commit out
+// of order and a stream resuming after an ID could miss an event committed
+// later with a smaller one. Instead each event is given a stream_seq as it
+// is dispatched, under a lock held until commit, so stream_seq follows
+// commit order across every instance.
+func (cfg *apiConfig) dispatchOutbox(ctx context.Context) {
+	for {
+		var dispatched []database.OutboxEvent
+		var claimed int
+		err := cfg.withTx(ctx, func(q *database.Queries) error {
+			if err := q.LockOutboxStream(ctx); err != nil {
+				return err
+			}
+			events, err := q.ClaimOutboxEvents(ctx, outboxBatchSize)
+			if err != nil {
+				return err
+			}
+			claimed = len(events)
+
+			for _, event := range events {
+				if _, err := q.CreateWebhookDeliveries(ctx, event.ID); err != nil {
+					return err
+				}
+				event, err := q.MarkOutboxEventDispatched(ctx, event.ID)
+				if err != nil {
+					return err
+				}
+				dispatched = append(dispatched, event)
+
+				// Notifications sent in a transaction are delivered when
+				// it commits, in commit order.
+				if cfg.streamBackend == streamBackendPostgres {
+					if err := q.NotifyOutboxEvent(ctx, strconv.FormatInt(event.ID, 10)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("Error dispatching outbox events", "error", err)
+			return
+		}
+		if cfg.streamBackend != streamBackendPostgres {
+			for _, event := range dispatched {
+				cfg.broker.Publish(brokerEventFromDB(event))
+			}
+		}
+		if claimed < outboxBatchSize {
+			return
+		}
+	}
+}
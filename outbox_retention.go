@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// pruneOutbox deletes finished webhook deliveries and dispatched outbox
+// events older than the configured retention period. Deliveries go first,
+// since an event is only deleted once no delivery refers to it.
+func (cfg *apiConfig) pruneOutbox(ctx context.Context) {
+	deliveries, err := cfg.db.DeleteFinishedWebhookDeliveries(ctx, cfg.outboxRetention.Seconds())
+	if err != nil {
+		slog.Error("Error pruning webhook deliveries", "error", err)
+		return
+	}
+	events, err := cfg.db.DeleteOldOutboxEvents(ctx, cfg.outboxRetention.Seconds())
+	if err != nil {
+		slog.Error("Error pruning outbox events", "error", err)
+		return
+	}
+	if deliveries > 0 || events > 0 {
+		slog.Info("Pruned outbox", "events", events, "deliveries", deliveries)
+	}
+}
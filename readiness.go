@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"net/http"
 )
 
@@ -10,6 +9,6 @@ func handlerReady(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte("OK"))
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
+		loggerFor(w).Error("Error writing response", "error", err)
 	}
 }
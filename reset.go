@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"net/http"
 )
 
@@ -12,17 +11,21 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Platform is "dev", proceed with reset operations
-	cfg.fileserverHits.Store(0)
+	if err := cfg.metrics.resetFileserverHits(); err != nil {
+		loggerFor(w).Error("Error resetting metrics", "error", err)
+		http.Error(w, "Error resetting metrics", http.StatusInternalServerError)
+		return
+	}
 
-	err := cfg.db.DeleteAllUsers(r.Context())
+	err := cfg.db.DeleteAllUsers(r.Context(), deletedUserID)
 	if err != nil {
-		log.Printf("Error deleting all users: %v", err)
+		loggerFor(w).Error("Error deleting all users", "error", err)
 		http.Error(w, "Error deleting all users", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if _, err = w.Write([]byte("Metrics reset and users deleted")); err != nil {
-		log.Printf("Error writing response: %v", err)
+		loggerFor(w).Error("Error writing response", "error", err)
 	}
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// shutdown stops the server in order, moving on from any step still running
+// when ctx is done:
+//
+//  1. Stop accepting connections, close SSE and WebSocket connections and
+//     wait for in-flight requests to finish.
+//  2. Stop the background workers, letting jobs in progress finish.
+//  3. Flush spans that haven't been exported yet.
+//  4. Close the database pool.
+func (cfg *apiConfig) shutdown(ctx context.Context, server *http.Server, workers *workerGroup, shutdownTracing func(context.Context) error) {
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Error draining HTTP requests", "error", err)
+	}
+	if err := waitGroupContext(ctx, &cfg.streams); err != nil {
+		slog.Error("Error closing streams", "error", err)
+	}
+
+	if err := workers.Stop(ctx); err != nil {
+		slog.Error("Error stopping background workers", "error", err)
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+
+	if err := cfg.dbConn.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+}
@@ -0,0 +1,50 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @user_id AND followee_id = @other_id)
+   OR (follower_id = @other_id AND followee_id = @user_id);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM user_mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: IsUserHidden :one
+SELECT EXISTS (
+  SELECT 1 FROM user_mutes
+  WHERE muter_id = @viewer_id AND muted_id = @user_id
+) OR EXISTS (
+  SELECT 1 FROM user_blocks
+  WHERE (blocker_id = @viewer_id AND blocked_id = @user_id)
+     OR (blocker_id = @user_id AND blocked_id = @viewer_id)
+);
+
+-- name: GetHiddenUserIDs :many
+SELECT muted_id AS user_id FROM user_mutes
+WHERE muter_id = @viewer_id
+UNION
+SELECT blocked_id FROM user_blocks
+WHERE blocker_id = @viewer_id
+UNION
+SELECT blocker_id FROM user_blocks
+WHERE blocked_id = @viewer_id;
@@ -0,0 +1,89 @@
+-- name: CreateChirpImport :one
+INSERT INTO chirp_imports (id, created_at, updated_at, user_id, status)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  'pending'
+  )
+RETURNING *;
+
+-- name: CreateChirpImportUpload :exec
+INSERT INTO chirp_import_uploads (import_id, data)
+VALUES ($1, $2);
+
+-- name: GetChirpImport :one
+SELECT * FROM chirp_imports
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetChirpImportErrors :many
+SELECT * FROM chirp_import_errors
+WHERE import_id = $1
+ORDER BY line ASC;
+
+-- name: ClaimChirpImports :many
+UPDATE chirp_imports
+SET status = 'running',
+    attempts = attempts + 1,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::float8),
+    updated_at = NOW()
+WHERE id IN (
+  SELECT id FROM chirp_imports
+  WHERE status = 'pending'
+     OR (status = 'running' AND lease_expires_at < NOW())
+  ORDER BY created_at ASC
+  LIMIT @max_results
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: GetChirpImportUpload :one
+SELECT data FROM chirp_import_uploads
+WHERE import_id = $1;
+
+-- name: CreateChirpImportError :exec
+INSERT INTO chirp_import_errors (import_id, line, error)
+VALUES ($1, $2, $3);
+
+-- name: AdvanceChirpImport :exec
+UPDATE chirp_imports
+SET position = @position,
+    imported_count = imported_count + @imported::int,
+    failed_count = failed_count + @failed::int,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::float8),
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: PauseChirpImport :exec
+-- A paused import is picked up again once its lease runs out. The pause
+-- doesn't count as a failed attempt.
+UPDATE chirp_imports
+SET attempts = attempts - 1,
+    lease_expires_at = NOW() + make_interval(secs => @resume_after_seconds::float8),
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: CompleteChirpImport :exec
+UPDATE chirp_imports
+SET status = 'completed',
+    lease_expires_at = NULL,
+    completed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailChirpImport :exec
+WITH deleted_upload AS (
+  DELETE FROM chirp_import_uploads
+  WHERE import_id = $1
+)
+UPDATE chirp_imports
+SET status = 'failed',
+    lease_expires_at = NULL,
+    error = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteChirpImportUpload :exec
+DELETE FROM chirp_import_uploads
+WHERE import_id = $1;
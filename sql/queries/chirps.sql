@@ -1,16 +1,45 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, reply_to_id)
 VALUES (
   gen_random_uuid(),
   NOW(),
   NOW(),
   $1,
-  $2
+  $2,
+  $3,
+  $4
   )
 RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL AND status = 'published'
+  AND (
+    visibility = 'public'
+    OR user_id = @viewer_id
+    OR (visibility = 'followers' AND EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = @viewer_id AND followee_id = chirps.user_id
+    ))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = @viewer_id AND muted_id = chirps.user_id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = @viewer_id AND blocked_id = chirps.user_id)
+       OR (blocker_id = chirps.user_id AND blocked_id = @viewer_id)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
 ORDER BY created_at ASC;
 
 -- name: GetChirp :one
@@ -18,10 +47,209 @@ SELECT * FROM chirps
 WHERE id = $1;
 
 -- name: DeleteChirp :exec
+UPDATE chirps
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = @id
+  AND user_id = @user_id
+  AND moderated_at IS NULL
+  AND deleted_at > NOW() - make_interval(secs => @restore_window_seconds::float8)
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
-WHERE id = $1 AND user_id = $2;
+WHERE deleted_at < NOW() - make_interval(secs => @retention_seconds::float8);
 
 -- name: GetChirpsByUser :many
 SELECT * FROM chirps
+WHERE user_id = @user_id AND deleted_at IS NULL AND status = 'published'
+  AND (
+    visibility IN ('public', 'unlisted')
+    OR user_id = @viewer_id
+    OR (visibility = 'followers' AND EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = @viewer_id AND followee_id = chirps.user_id
+    ))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = @viewer_id AND muted_id = chirps.user_id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = @viewer_id AND blocked_id = chirps.user_id)
+       OR (blocker_id = chirps.user_id AND blocked_id = @viewer_id)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
+ORDER BY created_at ASC;
+
+-- name: CreateDraft :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, visibility)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+  )
+RETURNING *;
+
+-- name: GetDraftsByUser :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled')
+ORDER BY created_at ASC;
+
+-- name: GetDraft :one
+SELECT * FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled');
+
+-- name: UpdateDraft :one
+UPDATE chirps
+SET body = $1,
+    status = $2,
+    publish_at = $3,
+    visibility = $4,
+    updated_at = NOW()
+WHERE id = $5 AND user_id = $6 AND status IN ('draft', 'scheduled')
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled');
+
+-- name: PublishDraft :one
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+RETURNING *;
+
+-- name: ClaimDueChirps :many
+-- Chirps by restricted authors wait until the restriction is lifted.
+SELECT * FROM chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+      AND (
+        users.banned_at IS NOT NULL
+        OR users.suspended_until > NOW()
+        OR users.deletion_requested_at IS NOT NULL
+      )
+  )
+ORDER BY publish_at ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PostponeScheduledChirp :exec
+UPDATE chirps
+SET publish_at = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1,
+    updated_at = NOW()
+WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL AND status = 'published'
+RETURNING *;
+
+-- name: CreateChirpRevision :exec
+INSERT INTO chirp_revisions (id, created_at, chirp_id, body)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2
+  );
+
+-- name: RemoveChirp :one
+UPDATE chirps
+SET deleted_at = COALESCE(deleted_at, NOW()),
+    moderated_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: AnonymizeRepliedChirps :many
+UPDATE chirps
+SET user_id = @deleted_user_id,
+    body = '',
+    updated_at = NOW()
+WHERE chirps.user_id = @user_id
+  AND (
+    EXISTS (
+      SELECT 1 FROM chirps AS replies
+      WHERE replies.reply_to_id = chirps.id AND replies.user_id <> @user_id
+    )
+    OR (
+      chirps.status = 'published'
+      AND EXISTS (
+        SELECT 1 FROM chirps AS parent
+        WHERE parent.id = chirps.reply_to_id AND parent.user_id <> @user_id
+      )
+    )
+  )
+RETURNING id;
+
+-- name: DeleteChirpRevisions :exec
+DELETE FROM chirp_revisions
+WHERE chirp_id = $1;
+
+-- name: GetChirpsForExport :many
+SELECT * FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC;
+
+-- name: GetChirpRevisionsByUser :many
+SELECT chirp_revisions.chirp_id, chirp_revisions.created_at, chirp_revisions.body
+FROM chirp_revisions
+JOIN chirps ON chirps.id = chirp_revisions.chirp_id
+WHERE chirps.user_id = $1
+ORDER BY chirp_revisions.created_at ASC;
+
+-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, imported_from_id)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  NOW(),
+  $2,
+  $3,
+  $4,
+  $5
+  )
+RETURNING *;
+
+-- name: HasImportedChirp :one
+SELECT EXISTS (
+  SELECT 1 FROM chirps
+  WHERE user_id = @user_id
+    AND (id = @archived_id OR imported_from_id = @archived_id)
+);
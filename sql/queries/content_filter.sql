@@ -0,0 +1,25 @@
+-- name: GetContentFilterRules :many
+SELECT * FROM content_filter_rules
+ORDER BY term ASC;
+
+-- name: CreateContentFilterRule :one
+INSERT INTO content_filter_rules (id, created_at, updated_at, term, action)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2
+  )
+RETURNING *;
+
+-- name: UpdateContentFilterRule :one
+UPDATE content_filter_rules
+SET action = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteContentFilterRule :execrows
+DELETE FROM content_filter_rules
+WHERE id = $1;
@@ -0,0 +1,95 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group, direct_key)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3
+  )
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversationForMember :one
+SELECT c.id, c.created_at, c.updated_at, c.created_by, c.is_group, c.direct_key
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE c.id = @id AND m.user_id = @user_id;
+
+-- name: GetConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at, c.is_group,
+       (SELECT COUNT(*) FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id IS DISTINCT FROM m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at)) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1
+ORDER BY c.updated_at DESC
+LIMIT $2;
+
+-- name: GetConversationMemberIDs :many
+SELECT conversation_id, user_id FROM conversation_members
+WHERE conversation_id = ANY(@conversation_ids::uuid[])
+ORDER BY joined_at ASC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: IsBlockedInConversation :one
+SELECT EXISTS (
+  SELECT 1 FROM conversation_members m
+  JOIN user_blocks b
+    ON (b.blocker_id = m.user_id AND b.blocked_id = @user_id)
+    OR (b.blocker_id = @user_id AND b.blocked_id = m.user_id)
+  WHERE m.conversation_id = @conversation_id AND m.user_id <> @user_id
+);
+
+-- name: IsBlockedBetween :one
+SELECT EXISTS (
+  SELECT 1 FROM user_blocks
+  WHERE (blocker_id = @user_id AND blocked_id = ANY(@other_ids::uuid[]))
+     OR (blocked_id = @user_id AND blocker_id = ANY(@other_ids::uuid[]))
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, key_id, ciphertext)
+VALUES (
+  $1,
+  NOW(),
+  $2,
+  $3,
+  $4,
+  $5
+  )
+RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = @conversation_id
+  AND (sqlc.narg(before_id)::uuid IS NULL OR (created_at, id) < (
+    SELECT created_at, id FROM messages WHERE id = sqlc.narg(before_id)
+  ))
+ORDER BY created_at DESC, id DESC
+LIMIT @max_results;
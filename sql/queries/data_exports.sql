@@ -0,0 +1,63 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  'pending'
+  )
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDataExports :many
+UPDATE data_exports
+SET status = 'running',
+    attempts = attempts + 1,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::float8),
+    updated_at = NOW()
+WHERE id IN (
+  SELECT id FROM data_exports
+  WHERE status = 'pending'
+     OR (status = 'running' AND lease_expires_at < NOW())
+  ORDER BY created_at ASC
+  LIMIT @max_results
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CreateDataExportArchive :exec
+INSERT INTO data_export_archives (export_id, archive)
+VALUES ($1, $2);
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'completed',
+    lease_expires_at = NULL,
+    completed_at = NOW(),
+    expires_at = NOW() + make_interval(secs => @retention_seconds::float8),
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed',
+    lease_expires_at = NULL,
+    error = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetDataExportArchive :one
+SELECT data_export_archives.archive
+FROM data_export_archives
+JOIN data_exports ON data_exports.id = data_export_archives.export_id
+WHERE data_export_archives.export_id = $1
+  AND data_exports.expires_at > NOW();
+
+-- name: DeleteExpiredDataExports :execrows
+DELETE FROM data_exports
+WHERE expires_at < NOW()
+   OR (status = 'failed' AND updated_at < NOW() - make_interval(secs => @retention_seconds::float8));
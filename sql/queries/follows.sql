@@ -0,0 +1,31 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+  SELECT 1 FROM follows
+  WHERE follower_id = $1 AND followee_id = $2
+);
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE muter_id = $1 AND muted_id = follows.followee_id
+  );
+
+-- name: GetFollowsForUser :many
+SELECT * FROM follows
+WHERE follower_id = @user_id OR followee_id = @user_id
+ORDER BY created_at ASC;
@@ -0,0 +1,17 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetLikesByUser :many
+SELECT * FROM likes
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,66 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, group_key, chirp_id, latest_actor_id, data)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+  )
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW(),
+              latest_actor_id = COALESCE(EXCLUDED.latest_actor_id, notifications.latest_actor_id),
+              data = EXCLUDED.data
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+  $1,
+  $2,
+  NOW()
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotifications :many
+SELECT n.id, n.created_at, n.updated_at, n.user_id, n.type, n.group_key, n.chirp_id,
+       n.latest_actor_id, n.data, n.read_at, actors.actor_count
+FROM notifications n
+CROSS JOIN LATERAL (
+  SELECT COUNT(*) AS actor_count FROM notification_actors a
+  WHERE a.notification_id = n.id
+    AND NOT EXISTS (
+      SELECT 1 FROM user_mutes
+      WHERE muter_id = n.user_id AND muted_id = a.actor_id
+    )
+    AND NOT EXISTS (
+      SELECT 1 FROM user_blocks
+      WHERE (blocker_id = n.user_id AND blocked_id = a.actor_id)
+         OR (blocker_id = a.actor_id AND blocked_id = n.user_id)
+    )
+) actors
+WHERE n.user_id = @user_id
+  AND (NOT @unread_only::bool OR n.read_at IS NULL)
+  AND (n.type IN ('subscription', 'moderation') OR actors.actor_count > 0)
+ORDER BY n.updated_at DESC
+LIMIT @max_results;
+
+-- name: CountNotificationActors :one
+SELECT COUNT(*) FROM notification_actors
+WHERE notification_id = $1;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE id = $1 AND user_id = $2 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = @user_id
+  AND read_at IS NULL
+  AND (cardinality(@ids::uuid[]) = 0 OR id = ANY(@ids::uuid[]));
@@ -0,0 +1,102 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox_events (created_at, event_type, user_id, public, payload)
+VALUES (
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4
+  )
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+SELECT * FROM outbox_events
+WHERE dispatched_at IS NULL
+ORDER BY id ASC
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: LockOutboxStream :exec
+SELECT pg_advisory_xact_lock(hashtext('outbox_stream_seq'));
+
+-- name: MarkOutboxEventDispatched :one
+UPDATE outbox_events
+SET dispatched_at = NOW(),
+    stream_seq = nextval('outbox_stream_seq')
+WHERE id = $1
+RETURNING *;
+
+-- name: NotifyOutboxEvent :exec
+SELECT pg_notify('outbox_events', @payload::text);
+
+-- name: GetOutboxEventsByIDs :many
+SELECT * FROM outbox_events
+WHERE id = ANY(@ids::bigint[])
+ORDER BY id ASC;
+
+-- name: GetPublicOutboxEventsAfter :many
+-- Only events dispatched within the replay window are returned. A
+-- chirp.created event is skipped once its chirp has been deleted, removed,
+-- made non-public or anonymized, or while its author is restricted, and
+-- comes with the chirp's current body so edits are reflected.
+SELECT e.stream_seq, e.event_type, e.payload,
+       c.body AS chirp_body, c.updated_at AS chirp_updated_at
+FROM outbox_events e
+LEFT JOIN chirps c
+  ON e.event_type = 'chirp.created' AND c.id = (e.payload->>'id')::uuid
+WHERE e.stream_seq > @after_seq::bigint
+  AND e.dispatched_at >= NOW() - make_interval(secs => @window_seconds::float8)
+  AND e.public
+  AND e.event_type = ANY(@event_types::text[])
+  AND (sqlc.narg(user_id)::uuid IS NULL OR e.user_id = sqlc.narg(user_id))
+  AND (
+    e.event_type <> 'chirp.created'
+    OR (
+      c.deleted_at IS NULL
+      AND c.status = 'published'
+      AND c.visibility = 'public'
+      AND c.user_id = e.user_id
+      AND NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = e.user_id
+          AND (
+            users.banned_at IS NOT NULL
+            OR users.suspended_until > NOW()
+            OR users.deletion_requested_at IS NOT NULL
+          )
+      )
+    )
+  )
+ORDER BY e.stream_seq ASC
+LIMIT @max_results;
+
+-- name: IsStreamPositionExpired :one
+-- A position is expired when events after it have left the replay window,
+-- or when the event it names has been pruned or never existed.
+SELECT EXISTS (
+  SELECT 1 FROM outbox_events
+  WHERE stream_seq > @after_seq::bigint
+    AND dispatched_at < NOW() - make_interval(secs => @window_seconds::float8)
+) OR (
+  @after_seq::bigint > 0 AND NOT EXISTS (
+    SELECT 1 FROM outbox_events
+    WHERE stream_seq = @after_seq::bigint
+  )
+);
+
+-- name: GetLatestStreamSeq :one
+SELECT COALESCE(MAX(stream_seq), 0)::bigint AS stream_seq
+FROM outbox_events;
+
+-- name: GetOutboxEventsByUser :many
+SELECT * FROM outbox_events
+WHERE user_id = $1
+ORDER BY id ASC;
+
+-- name: DeleteOldOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE dispatched_at < NOW() - make_interval(secs => @retention_seconds::float8)
+  AND NOT EXISTS (
+    SELECT 1 FROM webhook_deliveries
+    WHERE webhook_deliveries.event_id = outbox_events.id
+  );
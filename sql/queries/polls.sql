@@ -0,0 +1,57 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, expires_at)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2
+  )
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+  gen_random_uuid(),
+  $1,
+  $2,
+  $3
+  )
+RETURNING *;
+
+-- name: GetPollByChirpID :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsByChirpIDs :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetPollOptionTallies :many
+SELECT o.id, o.poll_id, o.position, o.text, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+WHERE o.poll_id = ANY(@poll_ids::uuid[])
+GROUP BY o.id
+ORDER BY o.poll_id, o.position ASC;
+
+-- name: GetUserPollVotes :many
+SELECT poll_id, option_id FROM poll_votes
+WHERE user_id = @user_id AND poll_id = ANY(@poll_ids::uuid[]);
+
+-- name: CreatePollVote :execrows
+INSERT INTO poll_votes (poll_id, user_id, option_id, created_at)
+SELECT p.id, @user_id::uuid, @option_id::uuid, NOW()
+FROM polls p
+WHERE p.id = @poll_id AND p.expires_at > NOW();
+
+-- name: DeletePollForChirp :exec
+DELETE FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollOptionsByUser :many
+SELECT polls.chirp_id, polls.expires_at, poll_options.text
+FROM polls
+JOIN chirps ON chirps.id = polls.chirp_id
+JOIN poll_options ON poll_options.poll_id = polls.id
+WHERE chirps.user_id = $1
+ORDER BY polls.chirp_id, poll_options.position ASC;
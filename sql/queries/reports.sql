@@ -0,0 +1,73 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  @reporter_id,
+  @user_id,
+  sqlc.narg('chirp_id'),
+  @reason,
+  @details
+  )
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: GetReports :many
+SELECT * FROM reports
+WHERE (@status::text = '' AND status <> 'resolved') OR status = @status
+ORDER BY created_at ASC
+LIMIT @max_results;
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed',
+    claimed_by = @moderator_id,
+    claimed_at = NOW(),
+    updated_at = NOW()
+WHERE id = @id
+  AND status <> 'resolved'
+  AND (claimed_by IS NULL OR claimed_by = @moderator_id)
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = 'resolved',
+    claimed_by = COALESCE(claimed_by, @moderator_id),
+    claimed_at = COALESCE(claimed_at, NOW()),
+    resolved_by = @moderator_id,
+    resolved_at = NOW(),
+    resolution = @resolution,
+    updated_at = NOW()
+WHERE id = @id
+  AND status <> 'resolved'
+  AND (claimed_by IS NULL OR claimed_by = @moderator_id)
+RETURNING *;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, user_id, chirp_id, action, reason, suspended_until)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  @moderator_id,
+  sqlc.narg('report_id'),
+  @user_id,
+  sqlc.narg('chirp_id'),
+  @action,
+  @reason,
+  sqlc.narg('suspended_until')
+  )
+RETURNING *;
+
+-- name: GetModerationActionsForReport :many
+SELECT * FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetModerationActionsForUser :many
+SELECT * FROM moderation_actions
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,45 @@
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4
+  )
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetSubscriptionStatus :execrows
+UPDATE subscriptions
+SET status = $1,
+    updated_at = NOW()
+WHERE user_id = $2;
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired',
+    updated_at = NOW()
+WHERE status = 'active' AND current_period_end <= NOW()
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, created_at, user_id, event, plan, period_end)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4
+  );
@@ -24,3 +24,13 @@ AND expires_at > NOW();
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -10,12 +10,17 @@ VALUES (
 RETURNING *;
 
 -- name: DeleteAllUsers :exec
-DELETE FROM users;
+DELETE FROM users
+WHERE id <> @deleted_user_id;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
 
+-- name: GetUserIDsByEmails :many
+SELECT id FROM users
+WHERE lower(email) = ANY(@emails::text[]);
+
 -- name: UpdateUser :one
 UPDATE users
 SET hashed_password = $1,
@@ -24,8 +29,58 @@ SET hashed_password = $1,
 WHERE id = $3
 RETURNING id, email, created_at, updated_at, is_chirpy_red;
 
--- name: SetUserPremiumByID :exec
+-- name: SyncUserPremium :execrows
+UPDATE users
+SET is_chirpy_red = EXISTS (
+      SELECT 1 FROM subscriptions
+      WHERE subscriptions.user_id = users.id
+        AND subscriptions.status = 'active'
+        AND subscriptions.current_period_end > NOW()
+    ),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: SuspendUser :exec
 UPDATE users
-SET is_chirpy_red = true,
+SET suspended_until = $2,
     updated_at = NOW()
 WHERE id = $1;
+
+-- name: BanUser :exec
+UPDATE users
+SET banned_at = COALESCE(banned_at, NOW()),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnbanUser :exec
+UPDATE users
+SET banned_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUsersDueForPurge :many
+SELECT id FROM users
+WHERE deletion_requested_at < NOW() - make_interval(secs => @grace_period_seconds::float8)
+ORDER BY deletion_requested_at ASC
+LIMIT @max_results;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
@@ -0,0 +1,50 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, received_at, updated_at, event_type, payload, status)
+VALUES (
+  $1,
+  NOW(),
+  NOW(),
+  $2,
+  $3,
+  'pending'
+  )
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: LockWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE status = @status OR @status::text = ''
+ORDER BY received_at DESC
+LIMIT @max_results;
+
+-- name: ListRetryableWebhookEvents :many
+SELECT id FROM webhook_events
+WHERE status = 'failed' AND attempts < @max_attempts
+ORDER BY received_at ASC
+LIMIT @max_results;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = $1,
+    attempts = attempts + 1,
+    last_error = NULL,
+    processed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed',
+    attempts = attempts + 1,
+    last_error = $1,
+    updated_at = NOW()
+WHERE id = $2;
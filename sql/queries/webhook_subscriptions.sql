@@ -0,0 +1,95 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, user_id, url, secret, events, active)
+VALUES (
+  gen_random_uuid(),
+  NOW(),
+  NOW(),
+  $1,
+  $2,
+  $3,
+  $4,
+  true
+  )
+RETURNING *;
+
+-- name: GetWebhookSubscriptionsByUser :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_id, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), s.id, e.id, 'pending', 0, NOW()
+FROM outbox_events e
+JOIN webhook_subscriptions s
+  ON s.active
+  AND e.event_type = ANY(s.events)
+  AND (e.public OR s.user_id = e.user_id)
+WHERE e.id = $1
+ON CONFLICT (subscription_id, event_id) DO NOTHING;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = NOW() + make_interval(secs => @lease_seconds::float8),
+    updated_at = NOW()
+FROM webhook_subscriptions s, outbox_events e
+WHERE d.subscription_id = s.id
+  AND d.event_id = e.id
+  AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at ASC
+    LIMIT @max_results
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING d.id, d.attempts, s.url, s.secret, e.id AS event_id, e.event_type, e.payload, e.created_at AS event_created_at;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    attempts = attempts + 1,
+    last_status_code = $1,
+    last_error = NULL,
+    delivered_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $1,
+    attempts = attempts + 1,
+    next_attempt_at = $2,
+    last_status_code = $3,
+    last_error = $4,
+    updated_at = NOW()
+WHERE id = $5;
+
+-- name: GetWebhookDeliveries :many
+SELECT d.id, d.created_at, d.updated_at, d.event_id, e.event_type, d.status, d.attempts,
+       d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at
+FROM webhook_deliveries d
+JOIN outbox_events e ON e.id = d.event_id
+WHERE d.subscription_id = $1
+ORDER BY d.created_at DESC
+LIMIT $2;
+
+-- name: RetryWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND subscription_id = $2 AND status = 'dead';
+
+-- name: DeleteFinishedWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+  AND updated_at < NOW() - make_interval(secs => @retention_seconds::float8);
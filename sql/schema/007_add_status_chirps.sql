@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+  CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD COLUMN publish_at TIMESTAMPTZ,
+ADD CONSTRAINT chirps_scheduled_publish_at_check
+  CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+CREATE INDEX chirps_scheduled_publish_at_idx ON chirps (publish_at)
+WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_scheduled_publish_at_idx;
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_scheduled_publish_at_check,
+DROP COLUMN publish_at,
+DROP COLUMN status;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE polls (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  chirp_id UUID NOT NULL UNIQUE REFERENCES chirps(id) ON DELETE CASCADE,
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE poll_options (
+  id UUID PRIMARY KEY,
+  poll_id UUID NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  text TEXT NOT NULL,
+  UNIQUE (poll_id, position),
+  UNIQUE (id, poll_id)
+);
+
+-- The primary key allows one vote per user and poll, and the composite
+-- foreign key keeps votes from pointing at another poll's option.
+CREATE TABLE poll_votes (
+  poll_id UUID NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  option_id UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (poll_id, user_id),
+  FOREIGN KEY (option_id, poll_id) REFERENCES poll_options(id, poll_id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
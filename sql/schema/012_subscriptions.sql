@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+  plan TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('active', 'canceled', 'expired')),
+  current_period_end TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE subscription_events (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  event TEXT NOT NULL,
+  plan TEXT NOT NULL,
+  period_end TIMESTAMPTZ
+);
+
+CREATE INDEX subscription_events_user_id_idx ON subscription_events (user_id);
+
+-- Users upgraded before subscriptions were tracked get a fresh period.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'chirpy_red', 'active', NOW() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
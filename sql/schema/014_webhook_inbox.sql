@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE webhook_events
+ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+ADD COLUMN event_type TEXT NOT NULL DEFAULT '',
+ADD COLUMN payload JSONB NOT NULL DEFAULT '{}',
+ADD COLUMN status TEXT NOT NULL DEFAULT 'processed'
+  CHECK (status IN ('pending', 'processed', 'ignored', 'failed')),
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_error TEXT,
+ADD COLUMN processed_at TIMESTAMP;
+
+ALTER TABLE webhook_events
+ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX webhook_events_failed_idx ON webhook_events (received_at)
+WHERE status = 'failed';
+
+-- +goose Down
+DROP INDEX webhook_events_failed_idx;
+
+ALTER TABLE webhook_events
+DROP COLUMN processed_at,
+DROP COLUMN last_error,
+DROP COLUMN attempts,
+DROP COLUMN status,
+DROP COLUMN payload,
+DROP COLUMN event_type,
+DROP COLUMN updated_at;
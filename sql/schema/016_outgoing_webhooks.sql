@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  event_type TEXT NOT NULL,
+  user_id UUID NOT NULL,
+  public BOOLEAN NOT NULL,
+  payload JSONB NOT NULL,
+  dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_events_undispatched_idx ON outbox_events (id)
+WHERE dispatched_at IS NULL;
+
+CREATE INDEX outbox_events_dispatched_at_idx ON outbox_events (dispatched_at)
+WHERE dispatched_at IS NOT NULL;
+
+CREATE TABLE webhook_subscriptions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT[] NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT true
+);
+
+CREATE INDEX webhook_subscriptions_user_id_idx ON webhook_subscriptions (user_id);
+
+CREATE TABLE webhook_deliveries (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  event_id BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+  last_status_code INTEGER,
+  last_error TEXT,
+  delivered_at TIMESTAMP,
+  UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+CREATE INDEX webhook_deliveries_event_id_idx ON webhook_deliveries (event_id);
+
+CREATE INDEX webhook_deliveries_finished_idx ON webhook_deliveries (updated_at)
+WHERE status <> 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+DROP TABLE outbox_events;
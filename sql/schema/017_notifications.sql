@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE likes (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type TEXT NOT NULL CHECK (type IN ('like', 'reply', 'mention', 'follow', 'subscription')),
+  group_key TEXT NOT NULL,
+  chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+  latest_actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  data JSONB NOT NULL DEFAULT '{}',
+  read_at TIMESTAMP
+);
+
+-- At most one unread notification per group, so new activity is coalesced
+-- into it.
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, group_key)
+WHERE read_at IS NULL;
+
+CREATE INDEX notifications_user_id_updated_at_idx ON notifications (user_id, updated_at DESC);
+
+CREATE TABLE notification_actors (
+  notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+  actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (notification_id, actor_id)
+);
+
+-- +goose Down
+DROP TABLE notification_actors;
+DROP TABLE notifications;
+DROP TABLE likes;
+ALTER TABLE chirps
+DROP COLUMN reply_to_id;
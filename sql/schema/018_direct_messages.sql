@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE conversations (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  is_group BOOLEAN NOT NULL,
+  -- The two member IDs in sorted order, so a pair of users has at most one
+  -- 1:1 conversation.
+  direct_key TEXT UNIQUE
+);
+
+CREATE TABLE conversation_members (
+  conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  joined_at TIMESTAMP NOT NULL,
+  last_read_at TIMESTAMP,
+  PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+CREATE TABLE messages (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  key_id TEXT NOT NULL,
+  ciphertext BYTEA NOT NULL
+);
+
+CREATE INDEX messages_conversation_id_created_at_idx ON messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
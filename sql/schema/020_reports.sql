@@ -0,0 +1,63 @@
+-- +goose Up
+-- moderated_at marks chirps removed by a moderator, which their authors
+-- can't restore.
+ALTER TABLE chirps
+ADD COLUMN moderated_at TIMESTAMP;
+
+CREATE TABLE reports (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  reason TEXT NOT NULL
+    CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+  details TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'open'
+    CHECK (status IN ('open', 'claimed', 'resolved')),
+  claimed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  claimed_at TIMESTAMP,
+  resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  resolved_at TIMESTAMP,
+  resolution TEXT
+);
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+
+-- A reporter can have one unresolved report per chirp, or per user for
+-- reports that aren't about a chirp.
+CREATE UNIQUE INDEX reports_unresolved_target_idx ON reports (reporter_id, COALESCE(chirp_id, user_id))
+WHERE status <> 'resolved';
+
+CREATE TABLE moderation_actions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  action TEXT NOT NULL
+    CHECK (action IN ('remove_chirp', 'warn', 'suspend', 'ban', 'dismiss')),
+  reason TEXT NOT NULL,
+  suspended_until TIMESTAMP
+);
+
+CREATE INDEX moderation_actions_user_id_idx ON moderation_actions (user_id, created_at);
+CREATE INDEX moderation_actions_report_id_idx ON moderation_actions (report_id);
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('like', 'reply', 'mention', 'follow', 'subscription', 'moderation'));
+
+-- +goose Down
+DELETE FROM notifications WHERE type = 'moderation';
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('like', 'reply', 'mention', 'follow', 'subscription'));
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE chirps
+DROP COLUMN moderated_at;
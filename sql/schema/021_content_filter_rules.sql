@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE content_filter_rules (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  term TEXT NOT NULL UNIQUE,
+  action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+-- The words validateChirp used to mask.
+INSERT INTO content_filter_rules (id, created_at, updated_at, term, action)
+VALUES
+  (gen_random_uuid(), NOW(), NOW(), 'kerfuffle', 'mask'),
+  (gen_random_uuid(), NOW(), NOW(), 'sharbert', 'mask'),
+  (gen_random_uuid(), NOW(), NOW(), 'fornax', 'mask');
+
+ALTER TABLE reports
+DROP CONSTRAINT reports_reason_check,
+ADD CONSTRAINT reports_reason_check
+  CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other', 'content_filter'));
+
+-- +goose Down
+DELETE FROM reports WHERE reason = 'content_filter';
+ALTER TABLE reports
+DROP CONSTRAINT reports_reason_check,
+ADD CONSTRAINT reports_reason_check
+  CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other'));
+DROP TABLE content_filter_rules;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP,
+ADD COLUMN banned_at TIMESTAMP;
+
+ALTER TABLE moderation_actions
+DROP CONSTRAINT moderation_actions_action_check,
+ADD CONSTRAINT moderation_actions_action_check
+  CHECK (action IN ('remove_chirp', 'warn', 'suspend', 'ban', 'dismiss', 'unsuspend', 'unban'));
+
+-- +goose Down
+DELETE FROM moderation_actions WHERE action IN ('unsuspend', 'unban');
+ALTER TABLE moderation_actions
+DROP CONSTRAINT moderation_actions_action_check,
+ADD CONSTRAINT moderation_actions_action_check
+  CHECK (action IN ('remove_chirp', 'warn', 'suspend', 'ban', 'dismiss'));
+ALTER TABLE users
+DROP COLUMN banned_at,
+DROP COLUMN suspended_until;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN deletion_requested_at TIMESTAMP;
+
+-- Placeholder author for chirps kept after their author's account is purged
+-- because other users replied to them. It has no usable password, so it
+-- can't log in. Its ID isn't the nil UUID, which stands for signed-out
+-- viewers.
+INSERT INTO users (id, created_at, updated_at, email)
+VALUES ('00000000-0000-0000-0000-000000000001', NOW(), NOW(), 'deleted-user@chirpy.invalid');
+
+-- +goose Down
+DELETE FROM users
+WHERE id = '00000000-0000-0000-0000-000000000001';
+
+ALTER TABLE users
+DROP COLUMN deletion_requested_at;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE data_exports (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  lease_expires_at TIMESTAMP,
+  error TEXT,
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP
+);
+
+-- At most one export in progress per user.
+CREATE UNIQUE INDEX data_exports_in_progress_idx ON data_exports (user_id)
+WHERE status IN ('pending', 'running');
+
+-- Archives are kept apart so listing and polling exports doesn't read them.
+CREATE TABLE data_export_archives (
+  export_id UUID PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+  archive BYTEA NOT NULL
+);
+
+-- +goose Down
+DROP TABLE data_export_archives;
+DROP TABLE data_exports;
@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE chirp_imports (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  lease_expires_at TIMESTAMP,
+  -- The last line of the upload that has been processed. A resumed import
+  -- carries on after it.
+  position INTEGER NOT NULL DEFAULT 0,
+  imported_count INTEGER NOT NULL DEFAULT 0,
+  failed_count INTEGER NOT NULL DEFAULT 0,
+  error TEXT,
+  completed_at TIMESTAMP
+);
+
+-- At most one import in progress per user.
+CREATE UNIQUE INDEX chirp_imports_in_progress_idx ON chirp_imports (user_id)
+WHERE status IN ('pending', 'running');
+
+-- Uploads are deleted once their import finishes.
+CREATE TABLE chirp_import_uploads (
+  import_id UUID PRIMARY KEY REFERENCES chirp_imports(id) ON DELETE CASCADE,
+  data BYTEA NOT NULL
+);
+
+CREATE TABLE chirp_import_errors (
+  import_id UUID NOT NULL REFERENCES chirp_imports(id) ON DELETE CASCADE,
+  line INTEGER NOT NULL,
+  error TEXT NOT NULL,
+  PRIMARY KEY (import_id, line)
+);
+
+-- The id a chirp had in the archive it was imported from, so importing the
+-- same archive again skips it.
+ALTER TABLE chirps ADD COLUMN imported_from_id UUID;
+
+CREATE UNIQUE INDEX chirps_imported_from_idx ON chirps (user_id, imported_from_id)
+WHERE imported_from_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_imported_from_idx;
+ALTER TABLE chirps DROP COLUMN imported_from_id;
+DROP TABLE chirp_import_errors;
+DROP TABLE chirp_import_uploads;
+DROP TABLE chirp_imports;
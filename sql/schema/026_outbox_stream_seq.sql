@@ -0,0 +1,15 @@
+-- +goose Up
+-- stream_seq is assigned when an event is dispatched, by one dispatcher at a
+-- time, so it follows commit order. Streams resume from it; outbox IDs can
+-- commit out of order.
+CREATE SEQUENCE outbox_stream_seq;
+ALTER TABLE outbox_events ADD COLUMN stream_seq BIGINT UNIQUE;
+
+-- Events already dispatched keep their IDs, so clients resuming with an
+-- earlier Last-Event-ID carry on from the same place.
+UPDATE outbox_events SET stream_seq = id WHERE dispatched_at IS NOT NULL;
+SELECT setval('outbox_stream_seq', (SELECT COALESCE(MAX(id), 0) + 1 FROM outbox_events), false);
+
+-- +goose Down
+ALTER TABLE outbox_events DROP COLUMN stream_seq;
+DROP SEQUENCE outbox_stream_seq;
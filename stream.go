@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/santokan/go-httpserver/internal/broker"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	streamBackendMemory   = "memory"
+	streamBackendPostgres = "postgres"
+)
+
+const outboxNotifyChannel = "outbox_events"
+
+// brokerEventFromDB converts a dispatched outbox event. Streams identify
+// events by their stream_seq, which follows commit order.
+func brokerEventFromDB(event database.OutboxEvent) broker.Event {
+	return broker.Event{
+		ID:     event.StreamSeq.Int64,
+		Type:   event.EventType,
+		UserID: event.UserID,
+		Public: event.Public,
+		Data:   event.Payload,
+	}
+}
+
+// publishEvents is called once outbox events have been committed. It wakes
+// the dispatcher, which sequences them and hands them to live streams. With
+// the memory backend they go straight to this instance's broker. With the
+// postgres backend they are announced with NOTIFY, and every instance's
+// listener (including this one's) publishes them to its own broker.
+func (cfg *apiConfig) publishEvents(_ context.Context, events ...database.OutboxEvent) {
+	if len(events) == 0 {
+		return
+	}
+	select {
+	case cfg.outboxWake <- struct{}{}:
+	default:
+		// A wake-up is already pending and will pick these events up.
+	}
+}
+
+// listenForEvents relays outbox events announced by any instance to this
+// instance's broker until ctx is cancelled.
+func (cfg *apiConfig) listenForEvents(ctx context.Context, dbURL string) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Event listener problem", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(outboxNotifyChannel); err != nil {
+		return fmt.Errorf("listening on %s: %w", outboxNotifyChannel, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established and
+			// notifications may have been missed. Clients recover them by
+			// resuming with Last-Event-ID.
+			if n == nil {
+				continue
+			}
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				slog.Error("Invalid outbox notification", "payload", n.Extra, "error", err)
+				continue
+			}
+			events, err := cfg.db.GetOutboxEventsByIDs(ctx, []int64{id})
+			if err != nil {
+				slog.Error("Error loading outbox event", "event_id", id, "error", err)
+				continue
+			}
+			for _, event := range events {
+				cfg.broker.Publish(brokerEventFromDB(event))
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+	"github.com/santokan/go-httpserver/internal/entitlements"
+)
+
+const (
+	subscriptionStatusActive   = "active"
+	subscriptionStatusCanceled = "canceled"
+	subscriptionStatusExpired  = "expired"
+)
+
+const (
+	polkaEventUserUpgraded        = "user.upgraded"
+	polkaEventUserDowngraded      = "user.downgraded"
+	polkaEventSubscriptionRenewed = "subscription.renewed"
+	polkaEventSubscriptionExpired = "subscription.expired"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+type subscriptionEvent struct {
+	Event            string
+	UserID           uuid.UUID
+	Plan             string
+	CurrentPeriodEnd *time.Time
+}
+
+// isSubscriptionEvent reports whether event changes a user's subscription.
+func isSubscriptionEvent(event string) bool {
+	switch event {
+	case polkaEventUserUpgraded, polkaEventUserDowngraded, polkaEventSubscriptionRenewed, polkaEventSubscriptionExpired:
+		return true
+	}
+	return false
+}
+
+// applySubscriptionEvent updates the user's subscription for a Polka event,
+// records it in the event history and re-derives is_chirpy_red from the
+// resulting subscription. The returned outbox events should be published
+// once q's transaction has committed.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, q *database.Queries, ev subscriptionEvent) ([]database.OutboxEvent, error) {
+	user, err := q.GetUserByID(ctx, ev.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+
+	plan := ev.Plan
+	if plan == "" {
+		plan = string(entitlements.PlanRed)
+	}
+
+	existing, err := q.GetSubscriptionByUserID(ctx, ev.UserID)
+	hasSubscription := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var periodEnd sql.NullTime
+	switch ev.Event {
+	case polkaEventUserUpgraded, polkaEventSubscriptionRenewed:
+		// Renewals extend the current period rather than restarting it, so
+		// an early renewal doesn't cost the user any time.
+		start := time.Now()
+		if ev.Event == polkaEventSubscriptionRenewed && hasSubscription && existing.CurrentPeriodEnd.After(start) {
+			start = existing.CurrentPeriodEnd
+		}
+		end := start.Add(cfg.subscriptionPeriod)
+		if ev.CurrentPeriodEnd != nil {
+			end = *ev.CurrentPeriodEnd
+		}
+		periodEnd = sql.NullTime{Time: end, Valid: true}
+
+		_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           ev.UserID,
+			Plan:             plan,
+			Status:           subscriptionStatusActive,
+			CurrentPeriodEnd: end,
+		})
+		if err != nil {
+			return nil, err
+		}
+	case polkaEventUserDowngraded, polkaEventSubscriptionExpired:
+		status := subscriptionStatusCanceled
+		if ev.Event == polkaEventSubscriptionExpired {
+			status = subscriptionStatusExpired
+		}
+		if hasSubscription {
+			plan = existing.Plan
+		}
+		_, err = q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+			Status: status,
+			UserID: ev.UserID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:    ev.UserID,
+		Event:     ev.Event,
+		Plan:      plan,
+		PeriodEnd: periodEnd,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return syncSubscriptionChange(ctx, q, user, ev.Event, plan)
+}
+
+// syncSubscriptionChange re-derives is_chirpy_red after a subscription
+// event, emits user.upgraded or user.downgraded if it changed, and notifies
+// the user.
+func syncSubscriptionChange(ctx context.Context, q *database.Queries, before database.User, event, plan string) ([]database.OutboxEvent, error) {
+	if _, err := q.SyncUserPremium(ctx, before.ID); err != nil {
+		return nil, err
+	}
+	after, err := q.GetUserByID(ctx, before.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []database.OutboxEvent
+	if after.IsChirpyRed != before.IsChirpyRed {
+		eventType := eventUserDowngraded
+		if after.IsChirpyRed {
+			eventType = eventUserUpgraded
+		}
+		outboxEvent, err := recordEvent(ctx, q, eventType, after.ID, false, userPlanEvent{
+			UserID: after.ID,
+			Plan:   plan,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, outboxEvent)
+	}
+
+	outboxEvent, err := notify(ctx, q, notificationParams{
+		UserID: after.ID,
+		Type:   notificationTypeSubscription,
+		Data: subscriptionNotification{
+			Event: event,
+			Plan:  plan,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if outboxEvent != nil {
+		events = append(events, *outboxEvent)
+	}
+	return events, nil
+}
+
+// expireLapsedSubscriptions expires active subscriptions whose period has
+// ended, so users lose Chirpy Red even if Polka's expiry webhook is missed.
+func (cfg *apiConfig) expireLapsedSubscriptions(ctx context.Context) {
+	var expired []database.Subscription
+	var events []database.OutboxEvent
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		var err error
+		expired, err = q.ExpireLapsedSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range expired {
+			err = q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+				UserID:    sub.UserID,
+				Event:     polkaEventSubscriptionExpired,
+				Plan:      sub.Plan,
+				PeriodEnd: sql.NullTime{Time: sub.CurrentPeriodEnd, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			user, err := q.GetUserByID(ctx, sub.UserID)
+			if err != nil {
+				return err
+			}
+			subEvents, err := syncSubscriptionChange(ctx, q, user, polkaEventSubscriptionExpired, sub.Plan)
+			if err != nil {
+				return err
+			}
+			events = append(events, subEvents...)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Error expiring lapsed subscriptions", "error", err)
+		return
+	}
+	cfg.publishEvents(ctx, events...)
+	if len(expired) > 0 {
+		slog.Info("Expired lapsed subscriptions", "count", len(expired))
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/santokan/go-httpserver/internal/database"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	traceExporterNone   = "none"
+	traceExporterOTLP   = "otlp"
+	traceExporterStdout = "stdout"
+)
+
+const serviceName = "chirpy"
+
+// tracer is taken from the global provider, so it picks up the provider
+// installed by setupTracing.
+var tracer = otel.Tracer("github.com/santokan/go-httpserver")
+
+// setupTracing installs the W3C trace context propagator and, unless
+// exporter is "none", a tracer provider sending spans to the exporter. The
+// OTLP exporter is configured through the standard OTEL_EXPORTER_OTLP_*
+// variables. It returns a function that flushes and stops the provider.
+func setupTracing(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", traceExporterNone:
+		// Without a provider spans aren't recorded, but trace IDs from
+		// incoming traceparent headers still reach the logs.
+		return func(context.Context) error { return nil }, nil
+	case traceExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case traceExporterStdout:
+		spanExporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("trace exporter must be %q, %q or %q", traceExporterNone, traceExporterOTLP, traceExporterStdout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// middlewareTracing starts a server span for every request, continuing the
+// trace from the traceparent header when the client sends one. The span is
+// named after the ServeMux pattern once the request has been routed.
+func middlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		// The request is copied to carry the span, so the pattern set by
+		// the ServeMux is only visible on the copy.
+		r = r.WithContext(ctx)
+		lw := &loggingResponseWriter{ResponseWriter: w, logger: slog.Default()}
+
+		next.ServeHTTP(lw, r)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// traceID returns the ID of the trace ctx belongs to, or "" if there is none.
+func traceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// tracedDB is a database.DBTX that records a child span for every query,
+// named after the sqlc query being run. Queries made outside a trace, such
+// as those of the background jobs, aren't traced.
+type tracedDB struct {
+	database.DBTX
+}
+
+func (db tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	result, err := db.DBTX.ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (db tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	stmt, err := db.DBTX.PrepareContext(ctx, query)
+	endQuerySpan(span, err)
+	return stmt, err
+}
+
+// QueryContext's span covers running the query, not reading the rows.
+func (db tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (db tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, noop.Span{}
+	}
+	return tracer.Start(ctx, queryName(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// queryName returns the name sqlc puts at the start of every query, as in
+// "-- name: GetChirp :one".
+func queryName(query string) string {
+	name, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "query"
+	}
+	if fields := strings.Fields(name); len(fields) > 0 {
+		return fields[0]
+	}
+	return "query"
+}
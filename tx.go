@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+// withTx runs fn inside a database transaction, committing if fn returns nil
+// and rolling back otherwise.
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(*database.Queries) error) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(database.New(tracedDB{tx})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/santokan/go-httpserver/internal/auth"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliverySucceeded = "succeeded"
+	webhookDeliveryDead      = "dead"
+)
+
+const (
+	maxWebhookDeliveryAttempts = 10
+	webhookDeliveryTimeout     = 10 * time.Second
+	webhookBackoffBase         = 30 * time.Second
+	webhookBackoffMax          = 6 * time.Hour
+	// webhookDeliveryLease is how long a claimed delivery is hidden from
+	// other workers. If the process dies mid-delivery, the delivery becomes
+	// due again once the lease runs out.
+	webhookDeliveryLease = time.Minute
+)
+
+type webhookEnvelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+var webhookClient = &http.Client{
+	Timeout:   webhookDeliveryTimeout,
+	Transport: newWebhookTransport(),
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// runWebhookWorkers delivers due webhooks on a pool of workers until ctx is
+// cancelled. Deliveries are claimed once per interval, at most a few per
+// worker at a time. Deliveries in progress when ctx is cancelled are
+// finished; claimed ones that haven't started are retried once their lease
+// runs out.
+func (cfg *apiConfig) runWebhookWorkers(ctx context.Context, workers int, interval time.Duration) {
+	jobs := make(chan database.ClaimDueWebhookDeliveriesRow)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range jobs {
+				cfg.deliverWebhook(context.WithoutCancel(ctx), delivery)
+			}
+		}()
+	}
+
+	runPeriodically(ctx, interval, func(jobCtx context.Context) {
+		deliveries, err := cfg.db.ClaimDueWebhookDeliveries(jobCtx, database.ClaimDueWebhookDeliveriesParams{
+			LeaseSeconds: webhookDeliveryLease.Seconds(),
+			MaxResults:   int32(workers * 4),
+		})
+		if err != nil {
+			slog.Error("Error claiming webhook deliveries", "error", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			select {
+			case jobs <- delivery:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	close(jobs)
+	wg.Wait()
+}
+
+// deliverWebhook posts one event to a subscriber and records the outcome.
+// Failed deliveries are retried with exponential backoff until they run out
+// of attempts, after which they are dead-lettered.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow) {
+	statusCode, err := postWebhook(ctx, delivery)
+	if err == nil {
+		err = cfg.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: true},
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			slog.Error("Error marking webhook delivery as succeeded", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	status := webhookDeliveryPending
+	if attempts >= maxWebhookDeliveryAttempts {
+		status = webhookDeliveryDead
+	}
+
+	markErr := cfg.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		Status:         status,
+		NextAttemptAt:  time.Now().Add(webhookBackoff(attempts)),
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+		ID:             delivery.ID,
+	})
+	if markErr != nil {
+		slog.Error("Error marking webhook delivery as failed", "delivery_id", delivery.ID, "error", markErr)
+	}
+}
+
+// postWebhook sends a signed delivery and returns the response status code,
+// or 0 if no response was received. Any non-2xx response is an error.
+func postWebhook(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow) (int, error) {
+	body, err := json.Marshal(webhookEnvelope{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("X-Chirpy-Event", delivery.EventType)
+	req.Header.Set("X-Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", timestamp)
+	req.Header.Set("X-Chirpy-Signature", auth.SignPayload(delivery.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff returns the delay before the next attempt of a delivery
+// that has failed attempts times: 30s, 1m, 2m, ... capped at 6h.
+func webhookBackoff(attempts int32) time.Duration {
+	delay := webhookBackoffBase
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookBackoffMax {
+			return webhookBackoffMax
+		}
+	}
+	return delay
+}
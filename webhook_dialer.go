@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errWebhookAddressNotAllowed = errors.New("webhook url must resolve to a public address")
+
+// blockedWebhookPrefixes are ranges that aren't covered by the netip
+// predicates used in webhookAddressAllowed but still reach internal
+// services.
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, includes some cloud metadata endpoints
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, includes broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, can map to any IPv4 address
+}
+
+// webhookAddressAllowed reports whether webhooks may be delivered to addr.
+// Loopback, private, unique-local, link-local (which includes the
+// 169.254.169.254 metadata endpoint) and other non-public addresses are
+// refused so subscriptions can't be used to reach internal services.
+func webhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookHost resolves host and fails if any of its addresses isn't
+// allowed. It gives subscribers an early error; deliveries are checked
+// again when they connect.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !webhookAddressAllowed(addr) {
+			return errWebhookAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// webhookDialControl runs after the address has been resolved and before
+// connecting, so a host that resolves to a public address when the
+// subscription is created and to an internal one later is still refused.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("webhook dial to %q: %w", address, err)
+	}
+	if !webhookAddressAllowed(addr) {
+		return errWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// newWebhookTransport returns a transport that only connects to allowed
+// addresses. Proxies are disabled, since the check would otherwise apply
+// to the proxy rather than to the subscriber's endpoint.
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   webhookDeliveryTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   webhookDialControl,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santokan/go-httpserver/internal/database"
+)
+
+const (
+	webhookStatusPending   = "pending"
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+const (
+	maxWebhookAttempts    = 5
+	webhookRetryBatchSize = 100
+)
+
+type polkaWebhook struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID  `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// processWebhookEvent applies a stored webhook event. The event row is
+// locked and its status updated in the same transaction as its effects, so
+// an event is applied at most once even if it is processed concurrently. An
+// event for a user that doesn't exist is marked as ignored, since retrying
+// it can't succeed, and errUserNotFound is returned. On any other failure
+// the event is marked as failed and kept for retry.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, eventID string) error {
+	var events []database.OutboxEvent
+	userNotFound := false
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		event, err := q.LockWebhookEvent(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		if event.Status == webhookStatusProcessed || event.Status == webhookStatusIgnored {
+			return nil
+		}
+
+		var payload polkaWebhook
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+
+		status := webhookStatusIgnored
+		if isSubscriptionEvent(payload.Event) {
+			events, err = cfg.applySubscriptionEvent(ctx, q, subscriptionEvent{
+				Event:            payload.Event,
+				UserID:           payload.Data.UserID,
+				Plan:             payload.Data.Plan,
+				CurrentPeriodEnd: payload.Data.CurrentPeriodEnd,
+			})
+			switch {
+			case errors.Is(err, errUserNotFound):
+				userNotFound = true
+			case err != nil:
+				return err
+			default:
+				status = webhookStatusProcessed
+			}
+		}
+
+		return q.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{
+			Status: status,
+			ID:     eventID,
+		})
+	})
+	if err != nil {
+		markErr := cfg.db.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+			ID:        eventID,
+		})
+		if markErr != nil {
+			slog.Error("Error marking webhook event as failed", "event_id", eventID, "error", markErr)
+		}
+		return err
+	}
+	if userNotFound {
+		return errUserNotFound
+	}
+	cfg.publishEvents(ctx, events...)
+	return nil
+}
+
+// retryFailedWebhookEvents reprocesses failed events that haven't used up
+// their attempts.
+func (cfg *apiConfig) retryFailedWebhookEvents(ctx context.Context) {
+	ids, err := cfg.db.ListRetryableWebhookEvents(ctx, database.ListRetryableWebhookEventsParams{
+		MaxAttempts: maxWebhookAttempts,
+		MaxResults:  webhookRetryBatchSize,
+	})
+	if err != nil {
+		slog.Error("Error listing failed webhook events", "error", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := cfg.processWebhookEvent(ctx, id); err != nil {
+			slog.Error("Error retrying webhook event", "event_id", id, "error", err)
+		}
+	}
+}